@@ -0,0 +1,169 @@
+package breach
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// magic identifies filter files and their format version
+var magic = [4]byte{'B', 'P', 'F', '1'}
+
+// Corpus formats accepted by Build
+const (
+	FormatSHA1  = "sha1"  // one uppercase or lowercase hex SHA-1 per line, optionally followed by ":count"
+	FormatPlain = "plain" // one password per line
+)
+
+// ErrInvalidFilter is returned when a file is not a breach filter
+var ErrInvalidFilter = errors.New("breach: not a breached password filter")
+
+// Filter is a Bloom filter of leaked passwords' SHA-1 hashes, built offline from a corpus
+// such as the Have I Been Pwned download. Contains may report false positives at the rate
+// the filter was built for, but never false negatives.
+type Filter struct {
+	hashes uint32 // probes per entry
+	bits   []byte
+}
+
+// New returns an empty filter sized for entries hashes at the false positive rate rate
+func New(entries int, rate float64) *Filter {
+	entries = max(entries, 1)
+	size := math.Ceil(-float64(entries) * math.Log(rate) / (math.Ln2 * math.Ln2))
+	hashes := math.Round(size / float64(entries) * math.Ln2)
+	return &Filter{
+		hashes: uint32(max(hashes, 1)),
+		bits:   make([]byte, (uint64(size)+7)/8),
+	}
+}
+
+// Add records a password by its SHA-1 digest
+func (f *Filter) Add(digest [sha1.Size]byte) {
+	f.probe(digest, func(byteIndex uint64, mask byte) bool {
+		f.bits[byteIndex] |= mask
+		return true
+	})
+}
+
+// Contains reports whether password is (probably) in the corpus
+func (f *Filter) Contains(password string) bool {
+	return f.ContainsDigest(sha1.Sum([]byte(password)))
+}
+
+// ContainsDigest reports whether the password with this SHA-1 digest is (probably) in the corpus
+func (f *Filter) ContainsDigest(digest [sha1.Size]byte) bool {
+	return f.probe(digest, func(byteIndex uint64, mask byte) bool {
+		return f.bits[byteIndex]&mask != 0
+	})
+}
+
+// probe visits the bit positions of digest until visit returns false. The digest is already
+// uniformly distributed, so its two halves serve as the double-hashing pair.
+func (f *Filter) probe(digest [sha1.Size]byte, visit func(byteIndex uint64, mask byte) bool) bool {
+	size := uint64(len(f.bits)) * 8
+	h1 := binary.BigEndian.Uint64(digest[0:8])
+	h2 := binary.BigEndian.Uint64(digest[8:16]) | 1
+	for i := uint64(0); i < uint64(f.hashes); i++ {
+		bit := (h1 + i*h2) % size
+		if !visit(bit/8, 1<<(bit%8)) {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteTo writes the filter in its file format
+func (f *Filter) WriteTo(w io.Writer) (int64, error) {
+	header := make([]byte, 16)
+	copy(header, magic[:])
+	binary.BigEndian.PutUint32(header[4:8], f.hashes)
+	binary.BigEndian.PutUint64(header[8:16], uint64(len(f.bits)))
+
+	n, err := w.Write(header)
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := w.Write(f.bits)
+	return int64(n + m), err
+}
+
+// Read parses a filter written by WriteTo
+func Read(r io.Reader) (*Filter, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, ErrInvalidFilter
+	}
+	if [4]byte(header[0:4]) != magic {
+		return nil, ErrInvalidFilter
+	}
+	hashes := binary.BigEndian.Uint32(header[4:8])
+	length := binary.BigEndian.Uint64(header[8:16])
+	if hashes == 0 || length == 0 {
+		return nil, ErrInvalidFilter
+	}
+
+	bits := make([]byte, length)
+	if _, err := io.ReadFull(r, bits); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+	}
+	return &Filter{hashes: hashes, bits: bits}, nil
+}
+
+// Load reads the filter file at path
+func Load(path string) (*Filter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Read(bufio.NewReader(file))
+}
+
+// Build creates a filter from a corpus with one entry per line in format, sized for
+// entries lines at the false positive rate rate
+func Build(corpus io.Reader, format string, entries int, rate float64) (*Filter, error) {
+	if format != FormatSHA1 && format != FormatPlain {
+		return nil, fmt.Errorf("breach: unknown corpus format %q", format)
+	}
+
+	filter := New(entries, rate)
+	scanner := bufio.NewScanner(corpus)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if format == FormatPlain {
+			filter.Add(sha1.Sum([]byte(text)))
+			continue
+		}
+
+		hash, _, _ := strings.Cut(strings.TrimSpace(text), ":")
+		if hash == "" {
+			continue
+		}
+		var digest [sha1.Size]byte
+		if n, err := hex.Decode(digest[:], []byte(hash)); err != nil || n != sha1.Size {
+			return nil, fmt.Errorf("breach: line %d is not a hex SHA-1 hash", line)
+		}
+		filter.Add(digest)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// CountLines returns the number of lines in r, for sizing a filter before Build
+func CountLines(r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	count := 0
+	for scanner.Scan() {
+		count++
+	}
+	return count, scanner.Err()
+}
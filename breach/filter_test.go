@@ -0,0 +1,96 @@
+package breach
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestBuildFormats(t *testing.T) {
+	leaked := []string{"123456", "password", "Passw0rd!", "qwerty"}
+
+	var sha1Corpus strings.Builder
+	for _, password := range leaked {
+		digest := sha1.Sum([]byte(password))
+		fmt.Fprintf(&sha1Corpus, "%s:%d\n", strings.ToUpper(hex.EncodeToString(digest[:])), 42)
+	}
+
+	corpora := map[string]string{
+		FormatSHA1:  sha1Corpus.String(),
+		FormatPlain: strings.Join(leaked, "\n") + "\n",
+	}
+	for format, corpus := range corpora {
+		t.Run(format, func(t *testing.T) {
+			filter, err := Build(strings.NewReader(corpus), format, len(leaked), 0.001)
+			if err != nil {
+				t.Fatalf("Build failed: %v", err)
+			}
+			for _, password := range leaked {
+				if !filter.Contains(password) {
+					t.Errorf("expected %q to be found", password)
+				}
+			}
+			if filter.Contains("Tr0ub4dor&3-unique-" + format) {
+				t.Error("expected an unknown password not to be found")
+			}
+		})
+	}
+}
+
+func TestBuildRejectsMalformedHashes(t *testing.T) {
+	_, err := Build(strings.NewReader("not-a-hash:1\n"), FormatSHA1, 1, 0.01)
+	if err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Fatalf("expected a line error, got %v", err)
+	}
+	if _, err := Build(strings.NewReader(""), "bcrypt", 1, 0.01); err == nil {
+		t.Fatal("expected an unknown format error")
+	}
+}
+
+func TestFalsePositiveRate(t *testing.T) {
+	const entries = 10000
+	filter := New(entries, 0.01)
+	for i := 0; i < entries; i++ {
+		filter.Add(sha1.Sum([]byte(fmt.Sprintf("leaked-%d", i))))
+	}
+
+	positives := 0
+	for i := 0; i < entries; i++ {
+		if filter.Contains(fmt.Sprintf("fresh-%d", i)) {
+			positives++
+		}
+	}
+	if rate := float64(positives) / entries; rate > 0.02 {
+		t.Fatalf("false positive rate %.4f exceeds twice the target", rate)
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	filter, _ := Build(strings.NewReader("hunter2\n"), FormatPlain, 1, 0.001)
+
+	var buf bytes.Buffer
+	if _, err := filter.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	loaded, err := Read(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if !loaded.Contains("hunter2") {
+		t.Fatal("expected the loaded filter to contain hunter2")
+	}
+
+	for name, data := range map[string][]byte{
+		"empty":     nil,
+		"bad magic": append([]byte("NOPE"), buf.Bytes()[4:]...),
+		"truncated": buf.Bytes()[:buf.Len()-1],
+	} {
+		if _, err := Read(bytes.NewReader(data)); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("%s: expected ErrInvalidFilter, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"azyqs-auth-systems/breach"
+	"azyqs-auth-systems/config"
+	"azyqs-auth-systems/controllers"
+	"azyqs-auth-systems/mailer"
+	"azyqs-auth-systems/models"
+	"azyqs-auth-systems/utils"
+	"azyqs-auth-systems/validators"
+)
+
+// runUser handles the "user" subcommands
+func runUser(args []string) {
+	if len(args) == 0 {
+		log.Fatal(usage)
+	}
+
+	switch args[0] {
+	case "create":
+		flags, loader := commandFlags("user create")
+		username := flags.String("username", "", "Username")
+		name := flags.String("name", "", "Display name")
+		email := flags.String("email", "", "Email address")
+		password := flags.String("password", "", "Password (read from stdin when empty)")
+		role := flags.String("role", models.RoleUser, "Role (user, admin)")
+		flags.Parse(args[1:])
+		cfg := loadConfig(loader)
+		if err := loadBreachedPasswords(cfg); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+
+		secret := passwordArgument(*password)
+		for _, err := range []error{
+			validators.ValidateUsername(*username),
+			validators.ValidateName(*name),
+			validators.ValidateEmail(*email),
+			validators.ValidatePassword(secret, *username, *name, *email),
+		} {
+			if err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+		}
+
+		h := newHandler(config.InitDB(cfg.Database), mailer.New(cfg.Mail))
+		if err := h.Auth.RegisterUser(context.Background(), *username, *name, *email, secret); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		user := lookupUser(h, *username)
+		if *role != models.RoleUser {
+			if err := h.Users.GrantRole(context.Background(), user.ID, *role); err != nil {
+				log.Fatalf("Error: user created but role not granted: %v", err)
+			}
+		}
+		log.Printf("Created user %s (%s) with role %s", user.Username, user.ID, *role)
+
+	case "set-password":
+		flags, loader := commandFlags("user set-password")
+		password := flags.String("password", "", "New password (read from stdin when empty)")
+		temporary := flags.Bool("temporary", false, "Require the user to change the password at the next login")
+		flags.Parse(args[1:])
+		username := positional(flags, 0)
+		cfg := loadConfig(loader)
+		if err := loadBreachedPasswords(cfg); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+
+		secret := passwordArgument(*password)
+		h := newHandler(config.InitDB(cfg.Database), mailer.New(cfg.Mail))
+		user := lookupUser(h, username)
+		if err := validators.ValidatePassword(secret, user.Username, user.Name, user.Email); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if err := h.Users.SetUserPassword(context.Background(), user.ID, secret, *temporary); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		log.Printf("Password of %s replaced and sessions revoked", username)
+
+	case "expire-password":
+		flags, loader := commandFlags("user expire-password")
+		flags.Parse(args[1:])
+		username := positional(flags, 0)
+		cfg := loadConfig(loader)
+
+		h := newHandler(config.InitDB(cfg.Database), mailer.New(cfg.Mail))
+		user := lookupUser(h, username)
+		if err := h.Users.ExpirePassword(context.Background(), user.ID); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		log.Printf("User %s must change their password at the next login; sessions revoked", username)
+
+	case "disable":
+		flags, loader := commandFlags("user disable")
+		flags.Parse(args[1:])
+		username := positional(flags, 0)
+		cfg := loadConfig(loader)
+
+		h := newHandler(config.InitDB(cfg.Database), mailer.New(cfg.Mail))
+		user := lookupUser(h, username)
+		if err := h.Users.DisableUser(context.Background(), user.ID); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		log.Printf("User %s disabled and sessions revoked", username)
+
+	case "grant-role":
+		flags, loader := commandFlags("user grant-role")
+		flags.Parse(args[1:])
+		username, role := positional(flags, 0), positional(flags, 1)
+		cfg := loadConfig(loader)
+
+		h := newHandler(config.InitDB(cfg.Database), mailer.New(cfg.Mail))
+		user := lookupUser(h, username)
+		if err := h.Users.GrantRole(context.Background(), user.ID, role); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		log.Printf("Granted role %s to %s", role, username)
+
+	default:
+		log.Fatal(usage)
+	}
+}
+
+// runKeys handles the "keys" subcommands
+func runKeys(args []string) {
+	if len(args) == 0 || args[0] != "rotate" {
+		log.Fatal(usage)
+	}
+
+	flags, loader := commandFlags("keys rotate")
+	file := flags.String("file", "", "Key file (default auth.jwt_keys_file)")
+	keep := flags.Int("keep", utils.DefaultKeysKept, "Number of keys kept for verifying older tokens; older keys only verify audit checkpoints")
+	flags.Parse(args[1:])
+	// The key file may not exist before the first rotation, so it is not loaded here
+	cfg := resolveConfig(loader)
+
+	path := *file
+	if path == "" {
+		path = cfg.Auth.JWTKeysFile
+	}
+	if path == "" {
+		log.Fatal("Error: no key file given; set auth.jwt_keys_file (JWT_KEYS_FILE) or pass -file")
+	}
+	if *keep < 2 {
+		log.Fatal("Error: -keep must be at least 2 so tokens signed with the previous key stay valid")
+	}
+
+	// The legacy secret is retired into the key file so audit checkpoints it signed stay
+	// verifiable after auth.jwt_secret is removed
+	keyID, err := utils.RotateKeyFile(path, *keep, []byte(cfg.Auth.JWTSecret))
+	if err != nil {
+		log.Fatalf("Error: failed to rotate keys: %v", err)
+	}
+	log.Printf("New active signing key %s written to %s; send SIGHUP to running servers or restart them to pick it up", keyID, path)
+}
+
+// runBreach handles the "breach" subcommands
+func runBreach(args []string) {
+	if len(args) == 0 || args[0] != "build" {
+		log.Fatal(usage)
+	}
+
+	flags := flag.NewFlagSet("breach build", flag.ExitOnError)
+	in := flags.String("in", "", "Corpus file, e.g. the Have I Been Pwned SHA-1 download")
+	out := flags.String("out", "", "Filter file to write (auth.breached_passwords_file)")
+	format := flags.String("format", breach.FormatSHA1, "Corpus format: sha1 (HASH[:count] lines) or plain (one password per line)")
+	rate := flags.Float64("rate", 0.001, "False positive rate")
+	flags.Parse(args[1:])
+
+	if *in == "" || *out == "" {
+		log.Fatal("Error: -in and -out are required")
+	}
+	if *rate <= 0 || *rate >= 1 {
+		log.Fatal("Error: -rate must be between 0 and 1")
+	}
+
+	// The corpus is read twice: once to size the filter, once to fill it
+	corpus, err := os.Open(*in)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	defer corpus.Close()
+	entries, err := breach.CountLines(corpus)
+	if err != nil {
+		log.Fatalf("Error: failed to read %s: %v", *in, err)
+	}
+	if _, err := corpus.Seek(0, io.SeekStart); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	filter, err := breach.Build(corpus, *format, entries, *rate)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	// Write to a temporary file first so a running server never reads a partial filter
+	tmp := *out + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if _, err := filter.WriteTo(file); err != nil {
+		file.Close()
+		log.Fatalf("Error: failed to write %s: %v", tmp, err)
+	}
+	if err := file.Close(); err != nil {
+		log.Fatalf("Error: failed to write %s: %v", tmp, err)
+	}
+	if err := os.Rename(tmp, *out); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	log.Printf("Breached password filter with %d entries written to %s", entries, *out)
+}
+
+// runSessions handles the "sessions" subcommands
+func runSessions(args []string) {
+	if len(args) == 0 || args[0] != "revoke" {
+		log.Fatal(usage)
+	}
+	flags, loader := commandFlags("sessions revoke")
+	flags.Parse(args[1:])
+	username := positional(flags, 0)
+	cfg := loadConfig(loader)
+
+	h := newHandler(config.InitDB(cfg.Database), mailer.New(cfg.Mail))
+	user := lookupUser(h, username)
+	if err := h.Users.RevokeSessions(context.Background(), user.ID); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	log.Printf("All sessions of %s revoked", username)
+}
+
+// runAudit handles the "audit" subcommands
+func runAudit(args []string) {
+	if len(args) == 0 || args[0] != "verify" {
+		log.Fatal(usage)
+	}
+
+	flags, loader := commandFlags("audit verify")
+	flags.Parse(args[1:])
+	cfg := loadConfig(loader)
+	h := newHandler(config.InitDB(cfg.Database), mailer.New(cfg.Mail))
+
+	result, err := h.Audit.VerifyAuditChain(context.Background())
+	if err != nil {
+		log.Fatalf("Audit verification failed: %v", err)
+	}
+	if !result.Valid {
+		log.Fatalf("Audit chain broken at sequence %d: %s", *result.BrokenAt, result.Reason)
+	}
+	log.Printf("Audit chain valid: %d events, %d checkpoints verified", result.CheckedEvents, result.CheckedCheckpoints)
+}
+
+// positional returns the i-th positional argument or exits with the usage
+func positional(flags *flag.FlagSet, i int) string {
+	if flags.NArg() <= i {
+		log.Fatal(usage)
+	}
+	return flags.Arg(i)
+}
+
+func lookupUser(h *controllers.Handler, username string) *models.User {
+	user, err := h.Users.GetUserByUsername(context.Background(), username)
+	if err != nil {
+		log.Fatalf("Error: %s: %v", username, err)
+	}
+	return user
+}
+
+// passwordArgument returns the flag value, or the first line of stdin when it is empty
+func passwordArgument(value string) string {
+	if value != "" {
+		return value
+	}
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		log.Fatal("Error: no password given")
+	}
+	return strings.TrimRight(line, "\r\n")
+}
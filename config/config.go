@@ -1,34 +1,390 @@
 package config
 
 import (
-	"log"
-	"os"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/joho/godotenv"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
+	"golang.org/x/crypto/bcrypt"
 )
 
-var DB *gorm.DB
+// Config is the complete application configuration.
+// Every leaf field can be set from the YAML file, an environment variable and a flag;
+// see Loader for the naming rules and precedence. Fields tagged `secret:"true"` may
+// hold a file:// or env:// reference instead of the value (see ResolveSecret).
+type Config struct {
+	Server         ServerConfig         `yaml:"server"`
+	Database       DatabaseConfig       `yaml:"database"`
+	Auth           AuthConfig           `yaml:"auth"`
+	PasswordPolicy PasswordPolicyConfig `yaml:"password_policy"`
+	CORS           CORSConfig           `yaml:"cors"`
+	RateLimit      RateLimitConfig      `yaml:"rate_limit"`
+	Mail           MailConfig           `yaml:"mail"`
+	Webhooks       WebhooksConfig       `yaml:"webhooks"`
+	Metrics        MetricsConfig        `yaml:"metrics"`
+	Tracing        TracingConfig        `yaml:"tracing"`
+	Logging        LoggingConfig        `yaml:"logging"`
+}
+
+// ServerConfig holds HTTP listener settings
+type ServerConfig struct {
+	Port              string        `yaml:"port" env:"PORT" flag:"port"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	MaxHeaderBytes    int           `yaml:"max_header_bytes"`
+	ShutdownDelay     time.Duration `yaml:"shutdown_delay"`   // how long /readyz fails before draining starts
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"` // how long to drain requests and workers
+}
+
+// DatabaseConfig holds the connection string and pool settings
+type DatabaseConfig struct {
+	URL             string        `yaml:"url" env:"DATABASE_URL" secret:"true"`
+	Password        string        `yaml:"password" env:"DATABASE_PASSWORD" secret:"true"` // overrides the URL's password
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+}
+
+// AuthConfig holds token and password hashing settings
+type AuthConfig struct {
+	JWTSecret             string        `yaml:"jwt_secret" env:"JWT_SECRET" secret:"true"`
+	JWTKeysFile           string        `yaml:"jwt_keys_file" env:"JWT_KEYS_FILE"`
+	AccessTokenTTL        time.Duration `yaml:"access_token_ttl"`
+	PasswordChangeTTL     time.Duration `yaml:"password_change_ttl"` // tokens that may only change the password
+	EmailChangeTTL        time.Duration `yaml:"email_change_ttl"`    // how long a new email address may be confirmed
+	ElevatedTokenTTL      time.Duration `yaml:"elevated_token_ttl"`  // tokens issued by re-authentication
+	RecentAuthMaxAge      time.Duration `yaml:"recent_auth_max_age"` // how fresh a re-authentication must be for sensitive routes
+	PasswordHash          string        `yaml:"password_hash"`       // algorithm of new hashes; older hashes are upgraded at login
+	BcryptCost            int           `yaml:"bcrypt_cost"`
+	Argon2Memory          int           `yaml:"argon2_memory"` // KiB
+	Argon2Iterations      int           `yaml:"argon2_iterations"`
+	Argon2Parallelism     int           `yaml:"argon2_parallelism"`
+	HashWorkers           int           `yaml:"hash_workers"`                                          // passwords hashed at once by "serve"
+	HashQueue             int           `yaml:"hash_queue"`                                            // hashes waiting for a worker before requests get 503
+	BreachedPasswordsFile string        `yaml:"breached_passwords_file" env:"BREACHED_PASSWORDS_FILE"` // built by "breach build"
+}
+
+// PasswordPolicyConfig is the policy new passwords must satisfy; zero numbers disable a rule
+type PasswordPolicyConfig struct {
+	MinLength            int     `yaml:"min_length"`
+	MaxLength            int     `yaml:"max_length"` // in characters; with bcrypt, passwords are also limited to 72 bytes
+	RequireUppercase     bool    `yaml:"require_uppercase"`
+	RequireLowercase     bool    `yaml:"require_lowercase"`
+	RequireDigit         bool    `yaml:"require_digit"`
+	RequireSpecial       bool    `yaml:"require_special"`
+	MaxRepeated          int     `yaml:"max_repeated"`           // identical characters in a row
+	DisallowPersonalInfo bool    `yaml:"disallow_personal_info"` // username, name and email
+	MinEntropy           float64 `yaml:"min_entropy"`            // estimated bits
+	History              int     `yaml:"history"`                // recent passwords, the current one included, that may not be reused
+	MaxAgeDays           int     `yaml:"max_age_days"`           // age after which the password must be changed at login
+}
+
+// CORSConfig controls cross-origin access from browsers
+type CORSConfig struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins"`
+	AllowedMethods   []string      `yaml:"allowed_methods"`
+	AllowedHeaders   []string      `yaml:"allowed_headers"`
+	AllowCredentials bool          `yaml:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age"`
+}
+
+// RateLimitConfig controls per-client request rate limiting
+type RateLimitConfig struct {
+	Enabled           bool     `yaml:"enabled"`
+	RequestsPerMinute int      `yaml:"requests_per_minute"`
+	Burst             int      `yaml:"burst"`
+	TrustedProxies    []string `yaml:"trusted_proxies"` // IPs or CIDRs whose X-Forwarded-For names the client
+}
+
+// MailConfig selects and configures the outgoing mail transport
+type MailConfig struct {
+	Driver      string `yaml:"driver"` // "log" or "smtp"
+	Host        string `yaml:"host"`
+	Port        int    `yaml:"port"`
+	Username    string `yaml:"username"`
+	Password    string `yaml:"password" secret:"true"`
+	From        string `yaml:"from"`
+	QueueSize   int    `yaml:"queue_size"`    // messages buffered for background sending
+	LinkBaseURL string `yaml:"link_base_url"` // public URL of the API, which serves the links in emails
+}
+
+// WebhooksConfig controls the webhook dispatcher
+type WebhooksConfig struct {
+	DispatchInterval time.Duration `yaml:"dispatch_interval"`
+}
+
+// MetricsConfig controls the Prometheus endpoint. It is served on its own address, never on
+// the public API port, because it exposes auth failure counts and database pool stats; keep
+// addr reachable only by the scraper.
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Addr    string `yaml:"addr"`
+	Path    string `yaml:"path"`
+}
+
+// TracingConfig controls OpenTelemetry span export
+type TracingConfig struct {
+	Enabled     bool    `yaml:"enabled"`
+	Exporter    string  `yaml:"exporter"` // "otlp", "stdout" or "file"
+	Endpoint    string  `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	File        string  `yaml:"file"`
+	ServiceName string  `yaml:"service_name" env:"OTEL_SERVICE_NAME"`
+	SampleRatio float64 `yaml:"sample_ratio"` // share of new traces recorded; propagated decisions are kept
+}
+
+// Trace exporters
+const (
+	TracingExporterOTLP   = "otlp"
+	TracingExporterStdout = "stdout"
+	TracingExporterFile   = "file"
+)
+
+// LoggingConfig controls the structured server log
+type LoggingConfig struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL"` // debug, info, warn or error
+	Format string `yaml:"format"`                // "json" or "text"
+}
 
-// InitDB initializes the database connection using environment variables.
-func InitDB() *gorm.DB {
-	// Load .env file if it exists, otherwise continue with existing environment variables.
-	if err := godotenv.Load(); err != nil {
-		log.Println("Warning: .env file not found, continuing with environment variables")
+// Log formats
+const (
+	LogFormatJSON = "json"
+	LogFormatText = "text"
+)
+
+// Mail drivers
+const (
+	MailDriverLog  = "log"
+	MailDriverSMTP = "smtp"
+)
+
+// Password hashing algorithms
+const (
+	PasswordHashArgon2id = "argon2id"
+	PasswordHashBcrypt   = "bcrypt"
+)
+
+// minJWTSecretLength is the minimum HS256 secret size accepted at startup
+const minJWTSecretLength = 32
+
+// Default returns the configuration used when nothing else is set
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:              "8080",
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			MaxHeaderBytes:    1 << 20,
+			ShutdownDelay:     5 * time.Second,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Database: DatabaseConfig{
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+		},
+		Auth: AuthConfig{
+			AccessTokenTTL:    time.Hour,
+			PasswordChangeTTL: 15 * time.Minute,
+			EmailChangeTTL:    24 * time.Hour,
+			ElevatedTokenTTL:  5 * time.Minute,
+			RecentAuthMaxAge:  5 * time.Minute,
+			PasswordHash:      PasswordHashArgon2id,
+			BcryptCost:        12,
+			Argon2Memory:      19 * 1024,
+			Argon2Iterations:  2,
+			Argon2Parallelism: 1,
+			HashWorkers:       runtime.NumCPU(),
+			HashQueue:         64,
+		},
+		PasswordPolicy: PasswordPolicyConfig{
+			MinLength:            8,
+			MaxLength:            72,
+			RequireUppercase:     true,
+			RequireLowercase:     true,
+			RequireDigit:         true,
+			RequireSpecial:       true,
+			MaxRepeated:          3,
+			DisallowPersonalInfo: true,
+			MinEntropy:           40,
+			History:              5,
+		},
+		CORS: CORSConfig{
+			AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
+			AllowedHeaders: []string{"Authorization", "Content-Type"},
+			MaxAge:         10 * time.Minute,
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:           true,
+			RequestsPerMinute: 120,
+			Burst:             30,
+		},
+		Mail: MailConfig{
+			Driver:      MailDriverLog,
+			Port:        587,
+			From:        "no-reply@localhost",
+			QueueSize:   100,
+			LinkBaseURL: "http://localhost:8080",
+		},
+		Webhooks: WebhooksConfig{
+			DispatchInterval: 5 * time.Second,
+		},
+		Metrics: MetricsConfig{
+			Enabled: true,
+			Addr:    ":9090",
+			Path:    "/metrics",
+		},
+		Tracing: TracingConfig{
+			Exporter:    TracingExporterOTLP,
+			Endpoint:    "http://localhost:4318",
+			File:        "traces.jsonl",
+			ServiceName: "azyqs-auth-systems",
+			SampleRatio: 1,
+		},
+		Logging: LoggingConfig{
+			Level:  "info",
+			Format: LogFormatJSON,
+		},
 	}
+}
 
-	dsn := os.Getenv("DATABASE_URL")
-	if dsn == "" {
-		log.Fatal("Error: DATABASE_URL is not set")
+// ParseTrustedProxy parses a rate_limit.trusted_proxies entry; a single IP is a one-address prefix
+func ParseTrustedProxy(proxy string) (netip.Prefix, error) {
+	if addr, err := netip.ParseAddr(proxy); err == nil {
+		return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
 	}
+	prefix, err := netip.ParsePrefix(proxy)
+	return prefix.Masked(), err
+}
 
-	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil {
-		log.Fatalf("Error: failed to connect to database: %v", err)
+// Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
 	}
 
-	log.Println("Database connection established successfully")
-	return DB
+	port, err := strconv.Atoi(c.Server.Port)
+	check(err == nil && port > 0 && port <= 65535, "server.port must be a number between 1 and 65535")
+	check(c.Server.ReadTimeout > 0, "server.read_timeout must be positive")
+	check(c.Server.ReadHeaderTimeout > 0, "server.read_header_timeout must be positive")
+	check(c.Server.WriteTimeout > 0, "server.write_timeout must be positive")
+	check(c.Server.IdleTimeout > 0, "server.idle_timeout must be positive")
+	check(c.Server.MaxHeaderBytes >= 4096, "server.max_header_bytes must be at least 4096")
+	check(c.Server.ShutdownDelay >= 0, "server.shutdown_delay must not be negative")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+
+	check(c.Database.URL != "", "database.url is required (DATABASE_URL)")
+	check(c.Database.MaxOpenConns >= 0, "database.max_open_conns must not be negative")
+	check(c.Database.MaxIdleConns >= 0, "database.max_idle_conns must not be negative")
+	check(c.Database.MaxOpenConns == 0 || c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"database.max_idle_conns must not exceed database.max_open_conns")
+	check(c.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime must not be negative")
+	check(c.Database.ConnMaxIdleTime >= 0, "database.conn_max_idle_time must not be negative")
+
+	check(c.Auth.JWTSecret != "" || c.Auth.JWTKeysFile != "",
+		"auth.jwt_secret (JWT_SECRET) or auth.jwt_keys_file (JWT_KEYS_FILE) is required")
+	check(c.Auth.JWTSecret == "" || len(c.Auth.JWTSecret) >= minJWTSecretLength,
+		"auth.jwt_secret must be at least %d bytes", minJWTSecretLength)
+	check(c.Auth.AccessTokenTTL > 0, "auth.access_token_ttl must be positive")
+	check(c.Auth.PasswordChangeTTL > 0, "auth.password_change_ttl must be positive")
+	check(c.Auth.EmailChangeTTL > 0, "auth.email_change_ttl must be positive")
+	check(c.Auth.ElevatedTokenTTL > 0, "auth.elevated_token_ttl must be positive")
+	check(c.Auth.RecentAuthMaxAge > 0, "auth.recent_auth_max_age must be positive")
+	check(c.Auth.PasswordHash == PasswordHashArgon2id || c.Auth.PasswordHash == PasswordHashBcrypt,
+		"auth.password_hash must be %q or %q", PasswordHashArgon2id, PasswordHashBcrypt)
+	check(c.Auth.BcryptCost >= bcrypt.MinCost && c.Auth.BcryptCost <= bcrypt.MaxCost,
+		"auth.bcrypt_cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	check(c.Auth.Argon2Iterations > 0, "auth.argon2_iterations must be positive")
+	check(c.Auth.Argon2Parallelism > 0 && c.Auth.Argon2Parallelism <= 255, "auth.argon2_parallelism must be between 1 and 255")
+	check(c.Auth.Argon2Memory >= 8*c.Auth.Argon2Parallelism, "auth.argon2_memory must be at least 8 KiB per unit of parallelism")
+	check(c.Auth.HashWorkers > 0, "auth.hash_workers must be positive")
+	check(c.Auth.HashQueue >= 0, "auth.hash_queue must not be negative")
+
+	check(c.PasswordPolicy.MinLength >= 1, "password_policy.min_length must be at least 1")
+	check(c.PasswordPolicy.MaxLength == 0 || c.PasswordPolicy.MaxLength >= c.PasswordPolicy.MinLength,
+		"password_policy.max_length must be 0 or at least password_policy.min_length")
+	check(c.PasswordPolicy.MaxRepeated >= 0, "password_policy.max_repeated must not be negative")
+	check(c.PasswordPolicy.MinEntropy >= 0, "password_policy.min_entropy must not be negative")
+	check(c.PasswordPolicy.History >= 0, "password_policy.history must not be negative")
+	check(c.PasswordPolicy.MaxAgeDays >= 0, "password_policy.max_age_days must not be negative")
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" {
+			check(!c.CORS.AllowCredentials, "cors.allowed_origins cannot contain \"*\" when cors.allow_credentials is true")
+			continue
+		}
+		parsed, err := url.Parse(origin)
+		check(err == nil && parsed.Scheme != "" && parsed.Host != "" && parsed.Path == "",
+			"cors.allowed_origins entry %q must be \"*\" or scheme://host[:port]", origin)
+	}
+	check(c.CORS.MaxAge >= 0, "cors.max_age must not be negative")
+
+	if c.RateLimit.Enabled {
+		check(c.RateLimit.RequestsPerMinute > 0, "rate_limit.requests_per_minute must be positive")
+		check(c.RateLimit.Burst > 0, "rate_limit.burst must be positive")
+		for _, proxy := range c.RateLimit.TrustedProxies {
+			_, err := ParseTrustedProxy(proxy)
+			check(err == nil, "rate_limit.trusted_proxies entry %q must be an IP address or CIDR", proxy)
+		}
+	}
+
+	switch c.Mail.Driver {
+	case MailDriverLog:
+	case MailDriverSMTP:
+		check(c.Mail.Host != "", "mail.host is required for the smtp driver")
+		check(c.Mail.Port > 0 && c.Mail.Port <= 65535, "mail.port must be between 1 and 65535")
+	default:
+		check(false, "mail.driver must be %q or %q", MailDriverLog, MailDriverSMTP)
+	}
+	check(c.Mail.From != "", "mail.from is required")
+	check(c.Mail.QueueSize > 0, "mail.queue_size must be positive")
+	linkBase, err := url.Parse(c.Mail.LinkBaseURL)
+	check(err == nil && (linkBase.Scheme == "http" || linkBase.Scheme == "https") && linkBase.Host != "",
+		"mail.link_base_url must be an http(s) URL")
+
+	check(c.Webhooks.DispatchInterval > 0, "webhooks.dispatch_interval must be positive")
+
+	if c.Metrics.Enabled {
+		_, metricsPort, err := net.SplitHostPort(c.Metrics.Addr)
+		check(err == nil && metricsPort != c.Server.Port, "metrics.addr must be host:port on a port other than server.port")
+		check(strings.HasPrefix(c.Metrics.Path, "/"), "metrics.path must start with \"/\"")
+	}
+
+	if c.Tracing.Enabled {
+		switch c.Tracing.Exporter {
+		case TracingExporterOTLP:
+			endpoint, err := url.Parse(c.Tracing.Endpoint)
+			check(err == nil && (endpoint.Scheme == "http" || endpoint.Scheme == "https") && endpoint.Host != "",
+				"tracing.endpoint must be an http(s) URL")
+		case TracingExporterStdout:
+		case TracingExporterFile:
+			check(c.Tracing.File != "", "tracing.file is required for the file exporter")
+		default:
+			check(false, "tracing.exporter must be %q, %q or %q", TracingExporterOTLP, TracingExporterStdout, TracingExporterFile)
+		}
+		check(c.Tracing.ServiceName != "", "tracing.service_name is required")
+		check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio must be between 0 and 1")
+	}
+
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Logging.Level)) == nil, "logging.level must be debug, info, warn or error")
+	check(c.Logging.Format == LogFormatJSON || c.Logging.Format == LogFormatText,
+		"logging.format must be %q or %q", LogFormatJSON, LogFormatText)
+
+	return errors.Join(errs...)
 }
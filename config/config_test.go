@@ -0,0 +1,238 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// load parses args into a fresh flag set and resolves the configuration
+func load(t *testing.T, args ...string) (*Config, error) {
+	t.Helper()
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	loader := NewLoader(flags)
+	if err := flags.Parse(args); err != nil {
+		t.Fatalf("failed to parse flags: %v", err)
+	}
+	return loader.Load()
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("JWT_SECRET", testSecret)
+	t.Setenv("DATABASE_URL", "postgres://env")
+	t.Setenv("AZYQS_DATABASE_MAX_OPEN_CONNS", "40")
+	t.Setenv("AZYQS_RATE_LIMIT_BURST", "7")
+
+	path := writeFile(t, `
+server:
+  port: "9000"
+database:
+  url: postgres://file
+  max_open_conns: 10
+  max_idle_conns: 2
+auth:
+  access_token_ttl: 15m
+cors:
+  allowed_origins: ["https://app.example.com"]
+`)
+
+	cfg, err := load(t, "-config", path, "-port", "9100", "-rate_limit.burst", "3", "-cors.allowed_methods", "GET, POST")
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	checks := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"flag alias beats file", cfg.Server.Port, "9100"},
+		{"env beats file", cfg.Database.URL, "postgres://env"},
+		{"derived env name", cfg.Database.MaxOpenConns, 40},
+		{"file beats default", cfg.Database.MaxIdleConns, 2},
+		{"file duration", cfg.Auth.AccessTokenTTL, 15 * time.Minute},
+		{"default kept", cfg.Auth.BcryptCost, 12},
+		{"flag beats env", cfg.RateLimit.Burst, 3},
+		{"file list", cfg.CORS.AllowedOrigins, []string{"https://app.example.com"}},
+		{"flag list", cfg.CORS.AllowedMethods, []string{"GET", "POST"}},
+	}
+	for _, c := range checks {
+		if !reflect.DeepEqual(c.got, c.want) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, c.got)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+
+	tests := []struct {
+		name  string
+		env   map[string]string
+		file  string
+		args  []string
+		wants []string
+	}{
+		{
+			name:  "missing required settings are all reported",
+			wants: []string{"database.url is required", "auth.jwt_secret (JWT_SECRET) or auth.jwt_keys_file"},
+		},
+		{
+			name: "invalid values",
+			env:  map[string]string{"DATABASE_URL": "postgres://x", "JWT_SECRET": "short"},
+			args: []string{"-auth.bcrypt_cost", "40", "-auth.password_hash", "md5", "-auth.argon2_memory", "4", "-auth.recent_auth_max_age", "0s", "-mail.driver", "pigeon", "-mail.link_base_url", "example.com", "-cors.allowed_origins", "example.com"},
+			wants: []string{
+				"auth.jwt_secret must be at least 32 bytes",
+				"auth.password_hash must be",
+				"auth.bcrypt_cost must be between",
+				"auth.argon2_memory must be at least",
+				"auth.recent_auth_max_age must be positive",
+				"mail.driver must be",
+				"mail.link_base_url must be",
+				`cors.allowed_origins entry "example.com"`,
+			},
+		},
+		{
+			name:  "unparsable duration",
+			args:  []string{"-auth.access_token_ttl", "soon"},
+			wants: []string{`invalid duration "soon" for auth.access_token_ttl`},
+		},
+		{
+			name:  "unknown file key",
+			file:  "server:\n  prot: \"8080\"\n",
+			wants: []string{"field prot not found"},
+		},
+		{
+			name: "inconsistent password policy",
+			env:  map[string]string{"DATABASE_URL": "postgres://x", "JWT_SECRET": testSecret},
+			args: []string{"-password_policy.min_length", "12", "-password_policy.max_length", "10", "-password_policy.min_entropy", "-1", "-password_policy.max_age_days", "-1"},
+			wants: []string{
+				"password_policy.max_length must be 0 or at least",
+				"password_policy.min_entropy must not be negative",
+				"password_policy.max_age_days must not be negative",
+			},
+		},
+		{
+			name:  "metrics on the API port",
+			env:   map[string]string{"DATABASE_URL": "postgres://x", "JWT_SECRET": testSecret},
+			args:  []string{"-server.port", "9090"},
+			wants: []string{"metrics.addr must be host:port on a port other than server.port"},
+		},
+		{
+			name:  "invalid trusted proxy",
+			env:   map[string]string{"DATABASE_URL": "postgres://x", "JWT_SECRET": testSecret},
+			args:  []string{"-rate_limit.trusted_proxies", "10.0.0.0/8,proxy.internal"},
+			wants: []string{`rate_limit.trusted_proxies entry "proxy.internal"`},
+		},
+		{
+			name:  "wildcard origin with credentials",
+			env:   map[string]string{"DATABASE_URL": "postgres://x", "JWT_SECRET": testSecret},
+			args:  []string{"-cors.allowed_origins", "*", "-cors.allow_credentials", "true"},
+			wants: []string{`cannot contain "*"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DATABASE_URL", "")
+			t.Setenv("JWT_SECRET", "")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeFile(t, tt.file)}, args...)
+			}
+
+			_, err := load(t, args...)
+			if err == nil {
+				t.Fatal("expected an error, got nil")
+			}
+			for _, want := range tt.wants {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("expected error to contain %q, got:\n%v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultIsValidOnceRequiredSettingsAreSet(t *testing.T) {
+	cfg := Default()
+	cfg.Database.URL = "postgres://x"
+	cfg.Auth.JWTSecret = testSecret
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected defaults to validate, got %v", err)
+	}
+}
+
+func TestSecretReferences(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "jwt_secret")
+	if err := os.WriteFile(secretFile, []byte(testSecret+"\n"), 0o600); err != nil {
+		t.Fatalf("failed to write secret file: %v", err)
+	}
+	t.Setenv("JWT_SECRET", "file://"+secretFile)
+	t.Setenv("DATABASE_URL", "env://MOUNTED_DATABASE_URL")
+	t.Setenv("MOUNTED_DATABASE_URL", "postgres://mounted")
+
+	cfg, err := load(t, "-mail.password", "literal-password")
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.Auth.JWTSecret != testSecret {
+		t.Errorf("expected the secret file contents without the newline, got %q", cfg.Auth.JWTSecret)
+	}
+	if cfg.Database.URL != "postgres://mounted" {
+		t.Errorf("expected the referenced environment variable, got %q", cfg.Database.URL)
+	}
+	if cfg.Mail.Password != "literal-password" {
+		t.Errorf("expected literal values to pass through, got %q", cfg.Mail.Password)
+	}
+
+	// Loading again picks up a rotated file
+	rotated := "fedcba9876543210fedcba9876543210"
+	if err := os.WriteFile(secretFile, []byte(rotated), 0o600); err != nil {
+		t.Fatalf("failed to rotate secret file: %v", err)
+	}
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	loader := NewLoader(flags)
+	flags.Parse(nil)
+	if cfg, err := loader.Load(); err != nil || cfg.Auth.JWTSecret != rotated {
+		t.Errorf("expected the rotated secret, got %v", err)
+	}
+
+	tests := []struct {
+		name string
+		ref  string
+		want string
+	}{
+		{"missing file", "file://" + filepath.Join(dir, "missing"), "auth.jwt_secret: secret file"},
+		{"unset variable", "env://NOT_SET_ANYWHERE", "secret environment variable NOT_SET_ANYWHERE is not set"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("JWT_SECRET", tt.ref)
+			_, err := load(t)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// databasePassword is used by every new pool connection so a rotated password applies without a restart
+var databasePassword atomic.Value
+
+// SetDatabasePassword replaces the password new database connections authenticate with;
+// an empty password keeps the one in the connection URL.
+func SetDatabasePassword(password string) {
+	databasePassword.Store(password)
+}
+
+// InitDB initializes the database connection and its pool from the database settings.
+func InitDB(cfg DatabaseConfig) *gorm.DB {
+	connConfig, err := pgx.ParseConfig(cfg.URL)
+	if err != nil {
+		slog.Error("invalid database url", "error", err)
+		os.Exit(1)
+	}
+	SetDatabasePassword(cfg.Password)
+
+	sqlDB := stdlib.OpenDB(*connConfig, stdlib.OptionBeforeConnect(func(ctx context.Context, cc *pgx.ConnConfig) error {
+		if password, _ := databasePassword.Load().(string); password != "" {
+			cc.Password = password
+		}
+		return nil
+	}))
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	if err != nil {
+		slog.Error("failed to connect to database", "error", err)
+		os.Exit(1)
+	}
+
+	slog.Info("database connection established")
+	return db
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix prefixes the derived environment variable names
+const envPrefix = "AZYQS_"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Loader resolves a Config from, in increasing precedence:
+// defaults, a YAML file, environment variables and command-line flags.
+//
+// Each setting is addressed by its YAML path, e.g. "database.max_open_conns".
+// Its environment variable is the `env` tag or AZYQS_ plus the upper-cased path
+// (AZYQS_DATABASE_MAX_OPEN_CONNS), and its flag is -database.max_open_conns
+// (plus the `flag` tag alias, if any). Lists are comma-separated. Secret references
+// are resolved last, so Load can be called again to pick up rotated secrets.
+type Loader struct {
+	flags      *flag.FlagSet
+	configFile *string
+	values     map[string]*string
+}
+
+// NewLoader registers -config and one flag per setting on flags.
+// Call Load after flags has been parsed.
+func NewLoader(flags *flag.FlagSet) *Loader {
+	l := &Loader{
+		flags:  flags,
+		values: map[string]*string{},
+	}
+	l.configFile = flags.String("config", os.Getenv("CONFIG_FILE"), "YAML configuration file (default from CONFIG_FILE)")
+
+	for _, f := range fieldsOf(Default()) {
+		value := new(string)
+		l.values[f.path] = value
+		usage := fmt.Sprintf("%s (env %s)", f.path, f.env)
+		flags.StringVar(value, f.path, "", usage)
+		if f.alias != "" {
+			flags.StringVar(value, f.alias, "", usage)
+		}
+	}
+	return l
+}
+
+// Load resolves and validates the configuration
+func (l *Loader) Load() (*Config, error) {
+	cfg := Default()
+
+	if *l.configFile != "" {
+		if err := loadFile(cfg, *l.configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	fields := fieldsOf(cfg)
+	for _, f := range fields {
+		if raw, ok := os.LookupEnv(f.env); ok {
+			if err := f.set(raw); err != nil {
+				return nil, fmt.Errorf("%s: %w", f.env, err)
+			}
+		}
+	}
+
+	set := map[string]bool{}
+	l.flags.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+	for _, f := range fields {
+		if set[f.path] || (f.alias != "" && set[f.alias]) {
+			if err := f.set(*l.values[f.path]); err != nil {
+				return nil, fmt.Errorf("-%s: %w", f.path, err)
+			}
+		}
+	}
+
+	for _, f := range fields {
+		if !f.secret {
+			continue
+		}
+		value, err := ResolveSecret(f.value.String())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.path, err)
+		}
+		f.value.SetString(value)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile decodes a YAML file over cfg, rejecting unknown keys
+func loadFile(cfg *Config, path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(raw))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// field is one settable leaf of Config
+type field struct {
+	path   string
+	env    string
+	alias  string
+	secret bool
+	value  reflect.Value
+}
+
+// fieldsOf lists the leaf settings of cfg in declaration order
+func fieldsOf(cfg *Config) []field {
+	var fields []field
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			name := strings.Split(sf.Tag.Get("yaml"), ",")[0]
+			path := name
+			if prefix != "" {
+				path = prefix + "." + name
+			}
+
+			if sf.Type.Kind() == reflect.Struct {
+				walk(v.Field(i), path)
+				continue
+			}
+
+			env := sf.Tag.Get("env")
+			if env == "" {
+				env = envPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+			}
+			fields = append(fields, field{
+				path:   path,
+				env:    env,
+				alias:  sf.Tag.Get("flag"),
+				secret: sf.Tag.Get("secret") == "true",
+				value:  v.Field(i),
+			})
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem(), "")
+	return fields
+}
+
+// set parses raw into the field according to its type
+func (f field) set(raw string) error {
+	raw = strings.TrimSpace(raw)
+
+	if f.value.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q for %s", raw, f.path)
+		}
+		f.value.SetInt(int64(d))
+		return nil
+	}
+
+	switch f.value.Kind() {
+	case reflect.String:
+		f.value.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q for %s", raw, f.path)
+		}
+		f.value.SetInt(int64(n))
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q for %s", raw, f.path)
+		}
+		f.value.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q for %s", raw, f.path)
+		}
+		f.value.SetBool(b)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		f.value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s for %s", f.value.Type(), f.path)
+	}
+	return nil
+}
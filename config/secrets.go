@@ -0,0 +1,36 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Secret reference schemes accepted by settings tagged `secret:"true"`
+const (
+	secretFileScheme = "file://"
+	secretEnvScheme  = "env://"
+)
+
+// ResolveSecret returns the value a secret setting refers to: the contents of PATH for
+// "file://PATH" (without the trailing newline), the variable NAME for "env://NAME",
+// or the value itself for anything else.
+func ResolveSecret(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, secretFileScheme):
+		path := strings.TrimPrefix(ref, secretFileScheme)
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("secret file: %w", err)
+		}
+		return strings.TrimRight(string(raw), "\r\n"), nil
+	case strings.HasPrefix(ref, secretEnvScheme):
+		name := strings.TrimPrefix(ref, secretEnvScheme)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("secret environment variable %s is not set", name)
+		}
+		return value, nil
+	}
+	return ref, nil
+}
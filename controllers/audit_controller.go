@@ -0,0 +1,19 @@
+package controllers
+
+import "net/http"
+
+// Verify Audit Chain: GET /audit/verify
+func (h *Handler) VerifyAudit(w http.ResponseWriter, r *http.Request) {
+	result, err := h.Audit.VerifyAuditChain(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if !result.Valid {
+		writeJSON(w, r, http.StatusConflict, "error", "audit_chain_broken", result)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, "success", "audit_chain_valid", result)
+}
@@ -2,13 +2,15 @@ package controllers
 
 import (
 	"azyqs-auth-systems/errors"
-	"azyqs-auth-systems/services"
+	"azyqs-auth-systems/middlewares"
 	"azyqs-auth-systems/validators"
 	"encoding/json"
 	"net/http"
+
+	"github.com/google/uuid"
 )
 
-func Register(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 	var userInput struct {
 		Username string `json:"username"`
 		Name     string `json:"name"`
@@ -17,81 +19,182 @@ func Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&userInput); err != nil {
-		writeJSON(w, http.StatusBadRequest, "error", errors.ErrInvalidInput.Error(), nil)
+		writeError(w, r, errors.ErrInvalidInput)
 		return
 	}
 
 	// Validasi input pengguna
-	if err := validators.ValidateUsername(userInput.Username); err != nil {
-		writeJSON(w, http.StatusBadRequest, "error", err.Error(), nil)
+	var result validators.Result
+	result.Check("username", validators.ValidateUsername(userInput.Username))
+	result.Check("name", validators.ValidateName(userInput.Name))
+	result.Check("email", validators.ValidateEmail(userInput.Email))
+	result.Check("password", validators.ValidatePassword(userInput.Password, userInput.Username, userInput.Name, userInput.Email))
+	if err := result.Err(); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	if err := validators.ValidateName(userInput.Name); err != nil {
-		writeJSON(w, http.StatusBadRequest, "error", err.Error(), nil)
+	// Lanjut ke service
+	err := h.Auth.RegisterUser(r.Context(), userInput.Username, userInput.Name, userInput.Email, userInput.Password)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	if err := validators.ValidateEmail(userInput.Email); err != nil {
-		writeJSON(w, http.StatusBadRequest, "error", err.Error(), nil)
+	writeJSON(w, r, http.StatusOK, "success", "registration_successful", nil)
+}
+
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Username string `json:"username"`
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, r, errors.ErrInvalidInput)
 		return
 	}
 
-	if err := validators.ValidatePassword(userInput.Password); err != nil {
-		writeJSON(w, http.StatusBadRequest, "error", err.Error(), nil)
+	// Validasi input pengguna; login memakai email bila diisi, selain itu username
+	var result validators.Result
+	identifier := input.Username
+	if input.Email != "" {
+		identifier = input.Email
+		result.Check("email", validators.ValidateEmail(input.Email))
+	} else {
+		result.Check("username", validators.ValidateUsername(input.Username))
+	}
+	result.Check("password", validators.RequirePassword(input.Password))
+	if err := result.Err(); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	// Lanjut ke service
-	err := services.RegisterUser(userInput.Username, userInput.Name, userInput.Email, userInput.Password)
+	login, err := h.Auth.LoginUser(r.Context(), identifier, input.Password)
 	if err != nil {
-		switch err {
-		case errors.ErrDuplicateRecord:
-			writeJSON(w, http.StatusBadRequest, "error", err.Error(), nil)
-		case errors.ErrPasswordHash:
-			writeJSON(w, http.StatusInternalServerError, "error", errors.ErrInternalServer.Error(), nil)
-		default:
-			writeJSON(w, http.StatusInternalServerError, "error", errors.ErrInternalServer.Error(), nil)
+		// Unknown users and wrong passwords look the same so usernames cannot be probed
+		if err == errors.ErrUserNotFound || err == errors.ErrInvalidPassword {
+			err = errors.ErrUnauthorized
 		}
+		writeError(w, r, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, "success", "registration_successful", nil)
+	if login.MustChangePassword {
+		writeJSON(w, r, http.StatusOK, "success", "password_change_required", login)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, "success", "login_successful", login)
 }
 
-func Login(w http.ResponseWriter, r *http.Request) {
+// Reauthenticate: POST /auth/reauthenticate
+func (h *Handler) Reauthenticate(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value(middlewares.UserIDKey).(string)
+	if !ok {
+		writeError(w, r, errors.ErrUserIDNotFound)
+		return
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		writeError(w, r, errors.ErrInvalidUserID)
+		return
+	}
+
 	var input struct {
-		Username string `json:"username"`
 		Password string `json:"password"`
 	}
-
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		writeJSON(w, http.StatusBadRequest, "error", errors.ErrInvalidInput.Error(), nil)
+		writeError(w, r, errors.ErrInvalidInput)
 		return
 	}
 
-	// Validasi input pengguna
-	if err := validators.ValidateUsername(input.Username); err != nil {
-		writeJSON(w, http.StatusBadRequest, "error", err.Error(), nil)
+	// Validasi password
+	var result validators.Result
+	result.Check("password", validators.RequirePassword(input.Password))
+	if err := result.Err(); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	if err := validators.ValidatePassword(input.Password); err != nil {
-		writeJSON(w, http.StatusBadRequest, "error", err.Error(), nil)
+	login, err := h.Auth.Reauthenticate(r.Context(), userID, input.Password)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	// Lanjut ke service
-	token, err := services.LoginUser(input.Username, input.Password)
+	writeJSON(w, r, http.StatusOK, "success", "reauthenticated", login)
+}
+
+// Email Change Link: GET /auth/email-change/{confirm,cancel}?token=
+// Mail scanners open links too, so opening one changes nothing; it returns the POST that does
+func (h *Handler) EmailChangeLink(w http.ResponseWriter, r *http.Request) {
+	token, err := emailChangeToken(r)
 	if err != nil {
-		switch err {
-		case errors.ErrUserNotFound, errors.ErrInvalidPassword:
-			writeJSON(w, http.StatusUnauthorized, "error", errors.ErrUnauthorized.Error(), nil)
-		default:
-			writeJSON(w, http.StatusInternalServerError, "error", errors.ErrInternalServer.Error(), nil)
-		}
+		writeError(w, r, err)
+		return
+	}
+
+	step := struct {
+		Method string `json:"method"`
+		Action string `json:"action"`
+		Token  string `json:"token"`
+	}{http.MethodPost, r.URL.Path, token}
+	writeJSON(w, r, http.StatusOK, "success", "email_link_opened", step)
+}
+
+// Confirm Email Change: POST /auth/email-change/confirm
+func (h *Handler) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	token, err := emailChangeToken(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := h.Users.ConfirmEmailChange(r.Context(), token); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, "success", "email_changed", nil)
+}
+
+// Cancel Email Change: POST /auth/email-change/cancel
+func (h *Handler) CancelEmailChange(w http.ResponseWriter, r *http.Request) {
+	token, err := emailChangeToken(r)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, "success", "login_successful", map[string]string{"token": token})
-}
\ No newline at end of file
+	if err := h.Users.CancelEmailChange(r.Context(), token); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, "success", "email_change_cancelled", nil)
+}
+
+// emailChangeToken reads the token of an email change link from the query string of a GET
+// or from the JSON body of a POST; either way a missing token fails validation
+func emailChangeToken(r *http.Request) (string, error) {
+	var input struct {
+		Token string `json:"token"`
+	}
+	if r.Method == http.MethodGet {
+		input.Token = r.URL.Query().Get("token")
+	} else if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		return "", errors.ErrInvalidInput
+	}
+
+	var result validators.Result
+	result.Check("token", validators.RequireToken(input.Token))
+	return input.Token, result.Err()
+}
+
+// Password Policy: GET /auth/password-policy
+func (h *Handler) PasswordPolicy(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, "success", "password_policy_found", validators.Policy)
+}
@@ -0,0 +1,12 @@
+package controllers
+
+import "azyqs-auth-systems/services"
+
+// Handler groups the HTTP handlers and the services they depend on
+type Handler struct {
+	Auth     *services.AuthService
+	Users    *services.UserService
+	Audit    *services.AuditService
+	Webhooks *services.WebhookService
+	Health   *services.HealthService
+}
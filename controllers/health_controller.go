@@ -0,0 +1,22 @@
+package controllers
+
+import "net/http"
+
+// Liveness Probe: GET /healthz
+func (h *Handler) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, "success", "alive", nil)
+}
+
+// Readiness Probe: GET /readyz
+func (h *Handler) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := h.Health.CheckReadiness(r.Context())
+
+	switch {
+	case report.ShuttingDown:
+		writeJSON(w, r, http.StatusServiceUnavailable, "error", "shutting_down", report)
+	case !report.Ready:
+		writeJSON(w, r, http.StatusServiceUnavailable, "error", "not_ready", report)
+	default:
+		writeJSON(w, r, http.StatusOK, "success", "ready", report)
+	}
+}
@@ -2,8 +2,9 @@ package controllers
 
 import (
 	"azyqs-auth-systems/errors"
+	"azyqs-auth-systems/i18n"
+	"azyqs-auth-systems/logging"
 	"azyqs-auth-systems/middlewares"
-	"azyqs-auth-systems/services"
 	"azyqs-auth-systems/validators"
 	"encoding/json"
 	"net/http"
@@ -11,57 +12,87 @@ import (
 	"github.com/google/uuid"
 )
 
-// Standard API response structure
+// Standard API response structure; Message is a stable code and LocalizedMessage its
+// text in the client's language
 type Response struct {
-	Status  string      `json:"status"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data,omitempty"`
+	Status           string              `json:"status"`
+	Message          string              `json:"message"`
+	LocalizedMessage string              `json:"localized_message"`
+	Data             interface{}         `json:"data,omitempty"`
+	Errors           map[string][]string `json:"errors,omitempty"`
 }
 
-func writeJSON(w http.ResponseWriter, statusCode int, status, message string, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(Response{
+func writeJSON(w http.ResponseWriter, r *http.Request, statusCode int, status, message string, data interface{}) {
+	writeResponse(w, r, statusCode, Response{
 		Status:  status,
 		Message: message,
 		Data:    data,
 	})
 }
 
+// busyRetryAfter is the Retry-After, in seconds, sent with 503 responses; the hashing queue
+// they come from drains within a second or two
+const busyRetryAfter = "1"
+
+// writeError writes the API error err maps to; internal errors are logged and never shown
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr, internal := errors.ToAPIError(err)
+	if internal {
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
+	}
+	if apiErr.Status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", busyRetryAfter)
+	}
+
+	writeResponse(w, r, apiErr.Status, Response{
+		Status:  "error",
+		Message: apiErr.Code,
+		Errors:  apiErr.Details,
+	})
+}
+
+// writeResponse localizes the message of resp and writes it
+func writeResponse(w http.ResponseWriter, r *http.Request, statusCode int, resp Response) {
+	resp.LocalizedMessage = i18n.Localize(w, r, resp.Message)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(resp)
+}
+
 // View Profile: GET /user/profile
-func ViewProfile(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) ViewProfile(w http.ResponseWriter, r *http.Request) {
 	userIDStr, ok := r.Context().Value(middlewares.UserIDKey).(string)
 	if !ok {
-		writeJSON(w, http.StatusUnauthorized, "error", errors.ErrUserIDNotFound.Error(), nil)
+		writeError(w, r, errors.ErrUserIDNotFound)
 		return
 	}
 
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, "error", errors.ErrInvalidUserID.Error(), nil)
+		writeError(w, r, errors.ErrInvalidUserID)
 		return
 	}
 
-	user, err := services.GetUserByID(userID)
+	user, err := h.Users.GetUserByID(r.Context(), userID)
 	if err != nil {
-		writeJSON(w, http.StatusNotFound, "error", errors.ErrUserNotFound.Error(), nil)
+		writeError(w, r, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, "success", "profile_found", user)
+	writeJSON(w, r, http.StatusOK, "success", "profile_found", user)
 }
 
 // Edit Profile: PUT /user/profile
-func EditProfile(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) EditProfile(w http.ResponseWriter, r *http.Request) {
 	userIDStr, ok := r.Context().Value(middlewares.UserIDKey).(string)
 	if !ok {
-		writeJSON(w, http.StatusUnauthorized, "error", errors.ErrUserIDNotFound.Error(), nil)
+		writeError(w, r, errors.ErrUserIDNotFound)
 		return
 	}
 
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, "error", errors.ErrInvalidUserID.Error(), nil)
+		writeError(w, r, errors.ErrInvalidUserID)
 		return
 	}
 
@@ -71,52 +102,87 @@ func EditProfile(w http.ResponseWriter, r *http.Request) {
 		Email    string `json:"email"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		writeJSON(w, http.StatusBadRequest, "error", errors.ErrInvalidInput.Error(), nil)
+		writeError(w, r, errors.ErrInvalidInput)
 		return
 	}
 
-	// Validasi input pengguna
+	// Validasi input pengguna; field kosong tidak diubah
+	var result validators.Result
 	if input.Username != "" {
-		if err := validators.ValidateUsername(input.Username); err != nil {
-			writeJSON(w, http.StatusBadRequest, "error", err.Error(), nil)
-			return
-		}
+		result.Check("username", validators.ValidateUsername(input.Username))
 	}
-
 	if input.Name != "" {
-		if err := validators.ValidateName(input.Name); err != nil {
-			writeJSON(w, http.StatusBadRequest, "error", err.Error(), nil)
-			return
-		}
+		result.Check("name", validators.ValidateName(input.Name))
 	}
-
 	if input.Email != "" {
-		if err := validators.ValidateEmail(input.Email); err != nil {
-			writeJSON(w, http.StatusBadRequest, "error", err.Error(), nil)
-			return
-		}
+		result.Check("email", validators.ValidateEmail(input.Email))
+	}
+	if err := result.Err(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = h.Users.UpdateUserProfile(r.Context(), userID, input.Username, input.Name, input.Email)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, "success", "profile_updated", nil)
+}
+
+// Change Email: PUT /user/email
+func (h *Handler) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value(middlewares.UserIDKey).(string)
+	if !ok {
+		writeError(w, r, errors.ErrUserIDNotFound)
+		return
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		writeError(w, r, errors.ErrInvalidUserID)
+		return
+	}
+
+	var input struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, r, errors.ErrInvalidInput)
+		return
 	}
 
-	err = services.UpdateUserProfile(userID, input.Username, input.Name, input.Email)
+	// Validasi email baru dan password saat ini
+	var result validators.Result
+	result.Check("email", validators.ValidateEmail(input.Email))
+	result.Check("password", validators.RequirePassword(input.Password))
+	if err := result.Err(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	change, err := h.Users.RequestEmailChange(r.Context(), userID, input.Email, input.Password)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, "error", err.Error(), nil)
+		writeError(w, r, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, "success", "profile_updated", nil)
+	writeJSON(w, r, http.StatusAccepted, "success", "email_change_requested", change)
 }
 
 // Delete Profile: DELETE /user/profile
-func DeleteProfile(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) DeleteProfile(w http.ResponseWriter, r *http.Request) {
 	userIDStr, ok := r.Context().Value(middlewares.UserIDKey).(string)
 	if !ok {
-		writeJSON(w, http.StatusUnauthorized, "error", errors.ErrUserIDNotFound.Error(), nil)
+		writeError(w, r, errors.ErrUserIDNotFound)
 		return
 	}
 
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, "error", errors.ErrInvalidUserID.Error(), nil)
+		writeError(w, r, errors.ErrInvalidUserID)
 		return
 	}
 
@@ -124,36 +190,38 @@ func DeleteProfile(w http.ResponseWriter, r *http.Request) {
 		Password string `json:"password"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		writeJSON(w, http.StatusBadRequest, "error", errors.ErrInvalidInput.Error(), nil)
+		writeError(w, r, errors.ErrInvalidInput)
 		return
 	}
 
 	// Validasi password
-	if err := validators.ValidatePassword(input.Password); err != nil {
-		writeJSON(w, http.StatusBadRequest, "error", err.Error(), nil)
+	var result validators.Result
+	result.Check("password", validators.RequirePassword(input.Password))
+	if err := result.Err(); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	err = services.DeleteUser(userID, input.Password)
+	err = h.Users.DeleteUser(r.Context(), userID, input.Password)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, "error", err.Error(), nil)
+		writeError(w, r, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, "success", "profile_deleted", nil)
+	writeJSON(w, r, http.StatusOK, "success", "profile_deleted", nil)
 }
 
 // Change Password: PUT /user/change-password
-func ChangePassword(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 	userIDStr, ok := r.Context().Value(middlewares.UserIDKey).(string)
 	if !ok {
-		writeJSON(w, http.StatusUnauthorized, "error", errors.ErrUserIDNotFound.Error(), nil)
+		writeError(w, r, errors.ErrUserIDNotFound)
 		return
 	}
 
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, "error", errors.ErrInvalidUserID.Error(), nil)
+		writeError(w, r, errors.ErrInvalidUserID)
 		return
 	}
 
@@ -163,31 +231,34 @@ func ChangePassword(w http.ResponseWriter, r *http.Request) {
 		ConfirmNewPassword string `json:"confirm_new_password"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		writeJSON(w, http.StatusBadRequest, "error", errors.ErrInvalidInput.Error(), nil)
+		writeError(w, r, errors.ErrInvalidInput)
 		return
 	}
 
-	// Validasi password
-	if err := validators.ValidatePassword(input.OldPassword); err != nil {
-		writeJSON(w, http.StatusBadRequest, "error", err.Error(), nil)
-		return
-	}
-
-	if err := validators.ValidatePassword(input.NewPassword); err != nil {
-		writeJSON(w, http.StatusBadRequest, "error", err.Error(), nil)
+	// The new password must not contain the user's own details
+	user, err := h.Users.GetUserByID(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
+	// Validasi password
+	var result validators.Result
+	result.Check("old_password", validators.RequirePassword(input.OldPassword))
+	result.Check("new_password", validators.ValidatePassword(input.NewPassword, user.Username, user.Name, user.Email))
 	if input.NewPassword != input.ConfirmNewPassword {
-		writeJSON(w, http.StatusBadRequest, "error", "password_mismatch", nil)
+		result.Check("confirm_new_password", errors.ErrPasswordMismatch)
+	}
+	if err := result.Err(); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	err = services.ChangeUserPassword(userID, input.OldPassword, input.NewPassword)
+	err = h.Users.ChangeUserPassword(r.Context(), userID, input.OldPassword, input.NewPassword)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, "error", err.Error(), nil)
+		writeError(w, r, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, "success", "password_changed", nil)
-}
\ No newline at end of file
+	writeJSON(w, r, http.StatusOK, "success", "password_changed", nil)
+}
@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"azyqs-auth-systems/errors"
+	"azyqs-auth-systems/services"
+	"azyqs-auth-systems/validators"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 500
+)
+
+// Create Webhook: POST /admin/webhooks
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+		Secret string   `json:"secret"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, r, errors.ErrInvalidInput)
+		return
+	}
+
+	if err := validators.ValidateWebhookURL(input.URL); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := validators.ValidateWebhookEvents(input.Events, services.WebhookEventTypes); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	endpoint, secret, err := h.Webhooks.CreateWebhookEndpoint(r.Context(), input.URL, input.Events, input.Secret)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// The secret is only returned once, at creation time
+	writeJSON(w, r, http.StatusCreated, "success", "webhook_created", map[string]interface{}{
+		"webhook": endpoint,
+		"secret":  secret,
+	})
+}
+
+// List Webhooks: GET /admin/webhooks
+func (h *Handler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	endpoints, err := h.Webhooks.ListWebhookEndpoints(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, "success", "webhooks_found", endpoints)
+}
+
+// Delete Webhook: DELETE /admin/webhooks/{id}
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, errors.ErrWebhookNotFound)
+		return
+	}
+
+	err = h.Webhooks.DeleteWebhookEndpoint(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, "success", "webhook_deleted", nil)
+}
+
+// List Webhook Deliveries: GET /admin/webhooks/{id}/deliveries?status=&limit=
+func (h *Handler) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, errors.ErrWebhookNotFound)
+		return
+	}
+
+	limit := defaultDeliveryLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxDeliveryLimit {
+			writeError(w, r, errors.ErrInvalidInput)
+			return
+		}
+		limit = parsed
+	}
+
+	deliveries, err := h.Webhooks.ListWebhookDeliveries(r.Context(), id, r.URL.Query().Get("status"), limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, "success", "deliveries_found", deliveries)
+}
+
+// Retry Webhook Delivery: POST /admin/webhooks/deliveries/{id}/retry
+func (h *Handler) RetryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, errors.ErrDeliveryNotFound)
+		return
+	}
+
+	err = h.Webhooks.RetryWebhookDelivery(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, "success", "delivery_requeued", nil)
+}
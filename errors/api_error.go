@@ -0,0 +1,102 @@
+package errors
+
+import (
+	"errors"
+	"net/http"
+)
+
+// APIError is an error as it is shown to API clients: an HTTP status, a stable code
+// and, for validation failures, the codes per input field
+type APIError struct {
+	Status  int
+	Code    string
+	Details map[string][]string
+}
+
+func (e *APIError) Error() string {
+	return e.Code
+}
+
+// ValidationError returns the error for input that failed validation, listing the codes per field
+func ValidationError(fields map[string][]string) *APIError {
+	return &APIError{Status: http.StatusBadRequest, Code: ErrValidationFailed.Error(), Details: fields}
+}
+
+// statuses maps every error that may be shown to clients to its HTTP status.
+// Errors not listed here are internal and reach clients as internal_server_error.
+var statuses = map[error]int{
+	ErrUsernameTaken:     http.StatusBadRequest,
+	ErrEmailTaken:        http.StatusBadRequest,
+	ErrDuplicateRecord:   http.StatusBadRequest,
+	ErrUserNotFound:      http.StatusNotFound,
+	ErrInvalidPassword:   http.StatusBadRequest,
+	ErrPasswordMismatch:  http.StatusBadRequest,
+	ErrInvalidInput:      http.StatusBadRequest,
+	ErrUserIDNotFound:    http.StatusUnauthorized,
+	ErrInvalidUserID:     http.StatusBadRequest,
+	ErrUnauthorized:      http.StatusUnauthorized,
+	ErrInternalServer:    http.StatusInternalServerError,
+	ErrAuditVerifyFailed: http.StatusInternalServerError,
+	ErrForbidden:         http.StatusForbidden,
+	ErrWebhookNotFound:   http.StatusNotFound,
+	ErrDeliveryNotFound:  http.StatusNotFound,
+	ErrUserDisabled:      http.StatusForbidden,
+	ErrInvalidRole:       http.StatusBadRequest,
+	ErrTokenRevoked:      http.StatusForbidden,
+	ErrEmailUnchanged:    http.StatusBadRequest,
+	ErrEmailNotEditable:  http.StatusBadRequest,
+	ErrInvalidEmailToken: http.StatusBadRequest,
+	ErrEmailChangeLocked: http.StatusConflict,
+
+	ErrTokenNotFound:          http.StatusForbidden,
+	ErrTokenInvalidFormat:     http.StatusForbidden,
+	ErrTokenExpired:           http.StatusForbidden,
+	ErrTokenInvalid:           http.StatusForbidden,
+	ErrPasswordChangeRequired: http.StatusForbidden,
+	ErrRecentAuthRequired:     http.StatusUnauthorized,
+
+	ErrValidationFailed:      http.StatusBadRequest,
+	ErrInvalidEmailFormat:    http.StatusBadRequest,
+	ErrUsernameTooShort:      http.StatusBadRequest,
+	ErrUsernameTooLong:       http.StatusBadRequest,
+	ErrInvalidUsernameFormat: http.StatusBadRequest,
+	ErrUsernameDotEdge:       http.StatusBadRequest,
+	ErrNameTooShort:          http.StatusBadRequest,
+	ErrNameTooLong:           http.StatusBadRequest,
+	ErrPasswordRequired:      http.StatusBadRequest,
+	ErrPasswordTooShort:      http.StatusBadRequest,
+	ErrPasswordTooLong:       http.StatusBadRequest,
+	ErrPasswordNoUppercase:   http.StatusBadRequest,
+	ErrPasswordNoLowercase:   http.StatusBadRequest,
+	ErrPasswordNoDigit:       http.StatusBadRequest,
+	ErrPasswordNoSpecial:     http.StatusBadRequest,
+	ErrPasswordRepeated:      http.StatusBadRequest,
+	ErrPasswordPersonalInfo:  http.StatusBadRequest,
+	ErrPasswordTooWeak:       http.StatusBadRequest,
+	ErrPasswordCompromised:   http.StatusBadRequest,
+	ErrPasswordReused:        http.StatusBadRequest,
+	ErrInvalidWebhookURL:     http.StatusBadRequest,
+	ErrWebhookEventsRequired: http.StatusBadRequest,
+	ErrEmailTokenRequired:    http.StatusBadRequest,
+	ErrUnknownWebhookEvent:   http.StatusBadRequest,
+
+	ErrRouteNotFound:    http.StatusNotFound,
+	ErrMethodNotAllowed: http.StatusMethodNotAllowed,
+	ErrRateLimited:      http.StatusTooManyRequests,
+	ErrServiceBusy:      http.StatusServiceUnavailable,
+}
+
+// ToAPIError maps err to the error shown to clients. The second result reports whether
+// err was internal, in which case only internal_server_error is shown and err should be logged.
+func ToAPIError(err error) (*APIError, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr, false
+	}
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if status, ok := statuses[e]; ok {
+			return &APIError{Status: status, Code: e.Error()}, false
+		}
+	}
+	return &APIError{Status: http.StatusInternalServerError, Code: ErrInternalServer.Error()}, true
+}
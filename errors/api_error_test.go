@@ -0,0 +1,49 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"azyqs-auth-systems/i18n"
+)
+
+func TestToAPIError(t *testing.T) {
+	validation := &APIError{Status: http.StatusBadRequest, Code: "validation_failed", Details: map[string][]string{"email": {"invalid_email_format"}}}
+
+	tests := []struct {
+		name         string
+		err          error
+		wantStatus   int
+		wantCode     string
+		wantInternal bool
+	}{
+		{"sentinel", ErrUserNotFound, http.StatusNotFound, "user_not_found", false},
+		{"wrapped sentinel", fmt.Errorf("loading profile: %w", ErrUsernameTaken), http.StatusBadRequest, "username_already_taken", false},
+		{"api error", validation, http.StatusBadRequest, "validation_failed", false},
+		{"deliberate server error", ErrAuditVerifyFailed, http.StatusInternalServerError, "audit_verify_failed", false},
+		{"internal sentinel", ErrUserUpdateFailed, http.StatusInternalServerError, "internal_server_error", true},
+		{"unknown error", errors.New("pq: connection refused"), http.StatusInternalServerError, "internal_server_error", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiErr, internal := ToAPIError(tt.err)
+			if apiErr.Status != tt.wantStatus || apiErr.Code != tt.wantCode || internal != tt.wantInternal {
+				t.Fatalf("got %d %q internal=%v, want %d %q internal=%v",
+					apiErr.Status, apiErr.Code, internal, tt.wantStatus, tt.wantCode, tt.wantInternal)
+			}
+		})
+	}
+}
+
+func TestEveryCodeIsTranslated(t *testing.T) {
+	for err := range statuses {
+		for _, lang := range []string{i18n.English, i18n.Indonesian} {
+			if !i18n.Has(lang, err.Error()) {
+				t.Errorf("%s has no %s text", err.Error(), lang)
+			}
+		}
+	}
+}
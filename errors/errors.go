@@ -3,18 +3,76 @@ package errors
 import "errors"
 
 var (
-	ErrUsernameTaken    = errors.New("username_already_taken")
-	ErrEmailTaken       = errors.New("email_already_taken")
-	ErrUserNotFound     = errors.New("user_not_found")
-	ErrInvalidPassword  = errors.New("invalid_password")
-	ErrPasswordHash     = errors.New("password_hash_error")
-	ErrDuplicateRecord  = errors.New("duplicate_record")
-	ErrUserDeleteFailed = errors.New("user_delete_failed")
-	ErrUserUpdateFailed = errors.New("user_update_failed")
-	ErrPasswordMismatch = errors.New("password_mismatch")
-	ErrInvalidInput     = errors.New("invalid_input")
-	ErrUserIDNotFound   = errors.New("user_id_not_found")
-	ErrInvalidUserID   = errors.New("user_id_is_invalid")
-	ErrUnauthorized     = errors.New("unauthorized")
-	ErrInternalServer   = errors.New("internal_server_error")
+	ErrUsernameTaken     = errors.New("username_already_taken")
+	ErrEmailTaken        = errors.New("email_already_taken")
+	ErrUserNotFound      = errors.New("user_not_found")
+	ErrInvalidPassword   = errors.New("invalid_password")
+	ErrPasswordHash      = errors.New("password_hash_error")
+	ErrDuplicateRecord   = errors.New("duplicate_record")
+	ErrRecordNotFound    = errors.New("record_not_found")
+	ErrUserDeleteFailed  = errors.New("user_delete_failed")
+	ErrUserUpdateFailed  = errors.New("user_update_failed")
+	ErrPasswordMismatch  = errors.New("password_mismatch")
+	ErrInvalidInput      = errors.New("invalid_input")
+	ErrUserIDNotFound    = errors.New("user_id_not_found")
+	ErrInvalidUserID     = errors.New("user_id_is_invalid")
+	ErrUnauthorized      = errors.New("unauthorized")
+	ErrInternalServer    = errors.New("internal_server_error")
+	ErrAuditVerifyFailed = errors.New("audit_verify_failed")
+	ErrForbidden         = errors.New("forbidden")
+	ErrWebhookNotFound   = errors.New("webhook_not_found")
+	ErrDeliveryNotFound  = errors.New("delivery_not_found")
+	ErrUserDisabled      = errors.New("user_disabled")
+	ErrInvalidRole       = errors.New("invalid_role")
+	ErrTokenRevoked      = errors.New("token_revoked")
+	ErrEmailUnchanged    = errors.New("email_unchanged")
+	ErrEmailNotEditable  = errors.New("email_change_requires_confirmation")
+	ErrInvalidEmailToken = errors.New("email_change_token_invalid")
+	ErrEmailChangeLocked = errors.New("email_change_locked")
+)
+
+// Token errors returned by the authentication middleware
+var (
+	ErrTokenNotFound          = errors.New("token_not_found")
+	ErrTokenInvalidFormat     = errors.New("token_invalid_format")
+	ErrTokenExpired           = errors.New("token_expired")
+	ErrTokenInvalid           = errors.New("token_invalid")
+	ErrPasswordChangeRequired = errors.New("password_change_required")
+	ErrRecentAuthRequired     = errors.New("reauthentication_required")
+)
+
+// Validation errors returned by the validators package
+var (
+	ErrValidationFailed      = errors.New("validation_failed")
+	ErrInvalidEmailFormat    = errors.New("invalid_email_format")
+	ErrUsernameTooShort      = errors.New("username_too_short")
+	ErrUsernameTooLong       = errors.New("username_too_long")
+	ErrInvalidUsernameFormat = errors.New("invalid_username_format")
+	ErrUsernameDotEdge       = errors.New("username_cannot_start_or_end_with_dot")
+	ErrNameTooShort          = errors.New("name_too_short")
+	ErrNameTooLong           = errors.New("name_too_long")
+	ErrPasswordRequired      = errors.New("password_required")
+	ErrPasswordTooShort      = errors.New("password_too_short")
+	ErrPasswordTooLong       = errors.New("password_too_long")
+	ErrPasswordNoUppercase   = errors.New("password_missing_uppercase")
+	ErrPasswordNoLowercase   = errors.New("password_missing_lowercase")
+	ErrPasswordNoDigit       = errors.New("password_missing_digit")
+	ErrPasswordNoSpecial     = errors.New("password_missing_special")
+	ErrPasswordRepeated      = errors.New("password_too_many_repeated_characters")
+	ErrPasswordPersonalInfo  = errors.New("password_contains_personal_info")
+	ErrPasswordTooWeak       = errors.New("password_too_weak")
+	ErrPasswordCompromised   = errors.New("password_compromised")
+	ErrPasswordReused        = errors.New("password_reused")
+	ErrInvalidWebhookURL     = errors.New("invalid_webhook_url")
+	ErrWebhookEventsRequired = errors.New("webhook_events_required")
+	ErrUnknownWebhookEvent   = errors.New("unknown_webhook_event")
+	ErrEmailTokenRequired    = errors.New("token_required")
+)
+
+// Routing errors
+var (
+	ErrRouteNotFound    = errors.New("route_not_found")
+	ErrMethodNotAllowed = errors.New("method_not_allowed")
+	ErrRateLimited      = errors.New("rate_limited")
+	ErrServiceBusy      = errors.New("service_busy")
 )
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"azyqs-auth-systems/mailer"
+	"azyqs-auth-systems/migrations"
+	"azyqs-auth-systems/services"
+	"azyqs-auth-systems/utils"
+
+	"gorm.io/gorm"
+)
+
+// readinessCheckTimeout bounds each /readyz dependency check
+const readinessCheckTimeout = 2 * time.Second
+
+// readinessChecks lists the dependencies /readyz reports on
+func readinessChecks(db *gorm.DB, migrator *migrations.Migrator, mail mailer.Mailer) []services.HealthCheck {
+	return []services.HealthCheck{
+		{Name: "database", Check: func(ctx context.Context) error {
+			sqlDB, err := db.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.PingContext(ctx)
+		}},
+		{Name: "migrations", Check: func(ctx context.Context) error {
+			pending, err := migrator.Pending()
+			if err != nil {
+				return err
+			}
+			if len(pending) > 0 {
+				return fmt.Errorf("%d migration(s) pending, first %04d_%s", len(pending), pending[0].Version, pending[0].Name)
+			}
+			return nil
+		}},
+		{Name: "signing_keys", Check: func(ctx context.Context) error {
+			if _, secret := utils.Keys.Active(); len(secret) == 0 {
+				return errors.New("no signing key loaded")
+			}
+			return nil
+		}},
+		{Name: "mailer", Check: mail.Ping},
+	}
+}
@@ -0,0 +1,178 @@
+package i18n
+
+// catalog holds the human-readable text for every message code, per language
+var catalog = map[string]map[string]string{
+	English: {
+		// Success messages
+		"registration_successful": "Registration successful.",
+		"login_successful":        "Login successful.",
+		"profile_found":           "Profile found.",
+		"profile_updated":         "Profile updated.",
+		"profile_deleted":         "Profile deleted.",
+		"password_changed":        "Password changed.",
+		"audit_chain_valid":       "The audit log is intact.",
+		"audit_chain_broken":      "The audit log has been tampered with.",
+		"webhook_created":         "Webhook created.",
+		"webhooks_found":          "Webhooks found.",
+		"webhook_deleted":         "Webhook deleted.",
+		"deliveries_found":        "Deliveries found.",
+		"delivery_requeued":       "Delivery queued for another attempt.",
+		"alive":                   "The service is running.",
+		"ready":                   "The service is ready.",
+		"not_ready":               "The service is not ready.",
+		"shutting_down":           "The service is shutting down.",
+		"email_change_requested":  "Check your new email address to confirm the change.",
+		"email_changed":           "Email address changed.",
+		"email_change_cancelled":  "Email change cancelled.",
+		"email_link_opened":       "Post this token back to finish.",
+		"reauthenticated":         "Password confirmed.",
+
+		// Errors
+		"username_already_taken": "This username is already taken.",
+		"email_already_taken":    "This email address is already in use.",
+		"duplicate_record":       "An account with this username or email already exists.",
+		"user_not_found":         "User not found.",
+		"invalid_password":       "The password is incorrect.",
+		"password_mismatch":      "The passwords do not match.",
+		"invalid_input":          "The request body is not valid JSON.",
+		"user_id_not_found":      "The request is not authenticated.",
+		"user_id_is_invalid":     "The user ID is invalid.",
+		"unauthorized":           "Incorrect username or password.",
+		"internal_server_error":  "Something went wrong on our side. Please try again later.",
+		"audit_verify_failed":    "The audit log could not be verified.",
+		"forbidden":              "You do not have permission to do this.",
+		"webhook_not_found":      "Webhook not found.",
+		"delivery_not_found":     "Delivery not found.",
+		"user_disabled":          "This account has been disabled.",
+		"invalid_role":           "The role is invalid.",
+		"token_revoked":          "This session has ended. Please log in again.",
+		"token_not_found":        "An access token is required.",
+		"token_invalid_format":   "The Authorization header must be \"Bearer <token>\".",
+		"token_expired":          "The access token has expired. Please log in again.",
+		"token_invalid":          "The access token is invalid.",
+
+		"validation_failed":                     "Some fields are invalid.",
+		"invalid_email_format":                  "The email address is invalid.",
+		"username_too_short":                    "The username must be at least 3 characters.",
+		"username_too_long":                     "The username must be at most 32 characters.",
+		"invalid_username_format":               "The username may only contain letters, digits and dots.",
+		"username_cannot_start_or_end_with_dot": "The username cannot start or end with a dot.",
+		"name_too_short":                        "The name must be at least 2 characters.",
+		"name_too_long":                         "The name must be at most 32 characters.",
+		"password_required":                     "A password is required.",
+		"password_too_short":                    "The password is too short.",
+		"password_too_long":                     "The password is too long.",
+		"password_missing_uppercase":            "The password must include an uppercase letter.",
+		"password_missing_lowercase":            "The password must include a lowercase letter.",
+		"password_missing_digit":                "The password must include a digit.",
+		"password_missing_special":              "The password must include a special character.",
+		"password_too_many_repeated_characters": "The password repeats the same character too many times in a row.",
+		"password_contains_personal_info":       "The password must not contain your username, name or email address.",
+		"password_too_weak":                     "The password is too easy to guess. Make it longer or more varied.",
+		"password_compromised":                  "This password has appeared in a data breach. Choose a different one.",
+		"password_reused":                       "You have used this password recently. Choose a different one.",
+		"password_policy_found":                 "Password policy found.",
+		"password_change_required":              "You must change your password before continuing.",
+		"reauthentication_required":             "Enter your password again to continue.",
+		"email_unchanged":                       "This is already your email address.",
+		"email_change_requires_confirmation":    "Change your email address through the confirmation flow.",
+		"email_change_token_invalid":            "This email change link is invalid or has expired.",
+		"email_change_locked":                   "Your email address was just changed; it can be changed again once the old address can no longer undo it.",
+		"invalid_webhook_url":                   "The webhook URL must be an absolute http or https URL.",
+		"webhook_events_required":               "At least one webhook event is required.",
+		"token_required":                        "The token from the email link is required.",
+		"unknown_webhook_event":                 "One of the webhook events is unknown.",
+
+		"route_not_found":    "This endpoint does not exist.",
+		"method_not_allowed": "This method is not allowed for this endpoint.",
+		"rate_limited":       "Too many requests. Please slow down.",
+		"service_busy":       "The service is busy. Please try again shortly.",
+	},
+
+	Indonesian: {
+		// Pesan sukses
+		"registration_successful": "Pendaftaran berhasil.",
+		"login_successful":        "Berhasil masuk.",
+		"profile_found":           "Profil ditemukan.",
+		"profile_updated":         "Profil diperbarui.",
+		"profile_deleted":         "Profil dihapus.",
+		"password_changed":        "Kata sandi diubah.",
+		"audit_chain_valid":       "Log audit utuh.",
+		"audit_chain_broken":      "Log audit telah diubah.",
+		"webhook_created":         "Webhook dibuat.",
+		"webhooks_found":          "Webhook ditemukan.",
+		"webhook_deleted":         "Webhook dihapus.",
+		"deliveries_found":        "Pengiriman ditemukan.",
+		"delivery_requeued":       "Pengiriman dijadwalkan ulang.",
+		"alive":                   "Layanan berjalan.",
+		"ready":                   "Layanan siap.",
+		"not_ready":               "Layanan belum siap.",
+		"shutting_down":           "Layanan sedang dimatikan.",
+		"email_change_requested":  "Periksa alamat email baru Anda untuk mengonfirmasi perubahan.",
+		"email_changed":           "Alamat email diubah.",
+		"email_change_cancelled":  "Perubahan email dibatalkan.",
+		"email_link_opened":       "Kirim kembali token ini untuk menyelesaikan.",
+		"reauthenticated":         "Kata sandi dikonfirmasi.",
+
+		// Kesalahan
+		"username_already_taken": "Nama pengguna ini sudah dipakai.",
+		"email_already_taken":    "Alamat email ini sudah digunakan.",
+		"duplicate_record":       "Akun dengan nama pengguna atau email ini sudah ada.",
+		"user_not_found":         "Pengguna tidak ditemukan.",
+		"invalid_password":       "Kata sandi salah.",
+		"password_mismatch":      "Kata sandi tidak cocok.",
+		"invalid_input":          "Isi permintaan bukan JSON yang valid.",
+		"user_id_not_found":      "Permintaan belum diautentikasi.",
+		"user_id_is_invalid":     "ID pengguna tidak valid.",
+		"unauthorized":           "Nama pengguna atau kata sandi salah.",
+		"internal_server_error":  "Terjadi kesalahan di sisi kami. Silakan coba lagi nanti.",
+		"audit_verify_failed":    "Log audit tidak dapat diverifikasi.",
+		"forbidden":              "Anda tidak memiliki izin untuk melakukan ini.",
+		"webhook_not_found":      "Webhook tidak ditemukan.",
+		"delivery_not_found":     "Pengiriman tidak ditemukan.",
+		"user_disabled":          "Akun ini telah dinonaktifkan.",
+		"invalid_role":           "Peran tidak valid.",
+		"token_revoked":          "Sesi ini telah berakhir. Silakan masuk kembali.",
+		"token_not_found":        "Token akses diperlukan.",
+		"token_invalid_format":   "Header Authorization harus berformat \"Bearer <token>\".",
+		"token_expired":          "Token akses telah kedaluwarsa. Silakan masuk kembali.",
+		"token_invalid":          "Token akses tidak valid.",
+
+		"validation_failed":                     "Beberapa isian tidak valid.",
+		"invalid_email_format":                  "Alamat email tidak valid.",
+		"username_too_short":                    "Nama pengguna minimal 3 karakter.",
+		"username_too_long":                     "Nama pengguna maksimal 32 karakter.",
+		"invalid_username_format":               "Nama pengguna hanya boleh berisi huruf, angka, dan titik.",
+		"username_cannot_start_or_end_with_dot": "Nama pengguna tidak boleh diawali atau diakhiri titik.",
+		"name_too_short":                        "Nama minimal 2 karakter.",
+		"name_too_long":                         "Nama maksimal 32 karakter.",
+		"password_required":                     "Kata sandi wajib diisi.",
+		"password_too_short":                    "Kata sandi terlalu pendek.",
+		"password_too_long":                     "Kata sandi terlalu panjang.",
+		"password_missing_uppercase":            "Kata sandi harus berisi huruf besar.",
+		"password_missing_lowercase":            "Kata sandi harus berisi huruf kecil.",
+		"password_missing_digit":                "Kata sandi harus berisi angka.",
+		"password_missing_special":              "Kata sandi harus berisi karakter khusus.",
+		"password_too_many_repeated_characters": "Kata sandi mengulang karakter yang sama terlalu banyak secara berurutan.",
+		"password_contains_personal_info":       "Kata sandi tidak boleh memuat nama pengguna, nama, atau alamat email Anda.",
+		"password_too_weak":                     "Kata sandi terlalu mudah ditebak. Buat lebih panjang atau lebih beragam.",
+		"password_compromised":                  "Kata sandi ini pernah bocor dalam insiden kebocoran data. Pilih kata sandi lain.",
+		"password_reused":                       "Kata sandi ini baru saja Anda gunakan. Pilih kata sandi lain.",
+		"password_policy_found":                 "Kebijakan kata sandi ditemukan.",
+		"password_change_required":              "Anda harus mengganti kata sandi sebelum melanjutkan.",
+		"reauthentication_required":             "Masukkan kata sandi Anda lagi untuk melanjutkan.",
+		"email_unchanged":                       "Ini sudah alamat email Anda.",
+		"email_change_requires_confirmation":    "Ubah alamat email melalui alur konfirmasi.",
+		"email_change_token_invalid":            "Tautan perubahan email ini tidak valid atau sudah kedaluwarsa.",
+		"email_change_locked":                   "Alamat email Anda baru saja diubah; ubah lagi setelah alamat lama tidak dapat membatalkannya.",
+		"invalid_webhook_url":                   "URL webhook harus berupa URL http atau https yang lengkap.",
+		"webhook_events_required":               "Minimal satu event webhook diperlukan.",
+		"token_required":                        "Token dari tautan email wajib diisi.",
+		"unknown_webhook_event":                 "Salah satu event webhook tidak dikenal.",
+
+		"route_not_found":    "Endpoint ini tidak ada.",
+		"method_not_allowed": "Metode ini tidak diizinkan untuk endpoint ini.",
+		"rate_limited":       "Terlalu banyak permintaan. Silakan coba lagi nanti.",
+		"service_busy":       "Layanan sedang sibuk. Silakan coba lagi sebentar lagi.",
+	},
+}
@@ -0,0 +1,61 @@
+package i18n
+
+import (
+	"net/http"
+
+	"golang.org/x/text/language"
+)
+
+// Supported languages
+const (
+	English    = "en"
+	Indonesian = "id"
+)
+
+// DefaultLanguage is used when the client accepts none of the supported languages
+const DefaultLanguage = English
+
+// supported lists the catalog languages; the first one is the fallback
+var supported = []language.Tag{language.English, language.Indonesian}
+
+var matcher = language.NewMatcher(supported)
+
+// Negotiate returns the supported language that best matches an Accept-Language header
+func Negotiate(acceptLanguage string) string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return DefaultLanguage
+	}
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return DefaultLanguage
+	}
+	return supported[index].String()
+}
+
+// Translate returns the text for a message code in lang, falling back to English and then
+// to the code itself
+func Translate(lang, code string) string {
+	if text, ok := catalog[lang][code]; ok {
+		return text
+	}
+	if text, ok := catalog[DefaultLanguage][code]; ok {
+		return text
+	}
+	return code
+}
+
+// Has reports whether code has a translation in lang
+func Has(lang, code string) bool {
+	_, ok := catalog[lang][code]
+	return ok
+}
+
+// Localize returns the text for code in the language negotiated from r's Accept-Language
+// header and announces that language on w
+func Localize(w http.ResponseWriter, r *http.Request, code string) string {
+	lang := Negotiate(r.Header.Get("Accept-Language"))
+	w.Header().Set("Content-Language", lang)
+	w.Header().Add("Vary", "Accept-Language")
+	return Translate(lang, code)
+}
@@ -0,0 +1,52 @@
+package i18n
+
+import "testing"
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", English},
+		{"id", Indonesian},
+		{"id-ID,id;q=0.9,en;q=0.8", Indonesian},
+		{"en-US,en;q=0.9,id;q=0.8", English},
+		{"fr-FR,id;q=0.5", Indonesian},
+		{"fr-FR,de;q=0.5", English},
+		{"*", English},
+		{"not a header;;", English},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := Negotiate(tt.header); got != tt.want {
+				t.Fatalf("Negotiate(%q) = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	if got := Translate(Indonesian, "user_not_found"); got != "Pengguna tidak ditemukan." {
+		t.Fatalf("unexpected Indonesian text %q", got)
+	}
+	if got := Translate("fr", "user_not_found"); got != "User not found." {
+		t.Fatalf("expected the English fallback, got %q", got)
+	}
+	if got := Translate(Indonesian, "no_such_code"); got != "no_such_code" {
+		t.Fatalf("expected the code as the last fallback, got %q", got)
+	}
+}
+
+func TestCatalogsMatch(t *testing.T) {
+	for code := range catalog[English] {
+		if !Has(Indonesian, code) {
+			t.Errorf("%s has no Indonesian text", code)
+		}
+	}
+	for code := range catalog[Indonesian] {
+		if !Has(English, code) {
+			t.Errorf("%s has no English text", code)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"azyqs-auth-systems/config"
+)
+
+// redacted replaces the value of sensitive attributes
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute key fragments whose values are never logged
+var sensitiveKeys = []string{"password", "token", "secret", "authorization", "cookie"}
+
+// jwtPattern matches JSON Web Tokens embedded in free-form strings
+var jwtPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`)
+
+// NewHandler returns a slog handler writing to w in the configured format and level,
+// with passwords and tokens redacted
+func NewHandler(w io.Writer, cfg config.LoggingConfig) slog.Handler {
+	var level slog.Level
+	level.UnmarshalText([]byte(cfg.Level)) // validated by config
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+
+	if cfg.Format == config.LogFormatText {
+		return slog.NewTextHandler(w, opts)
+	}
+	return slog.NewJSONHandler(w, opts)
+}
+
+// redact hides sensitive attribute values and tokens inside any string value
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	key := strings.ToLower(attr.Key)
+	for _, fragment := range sensitiveKeys {
+		if strings.Contains(key, fragment) {
+			return slog.String(attr.Key, redacted)
+		}
+	}
+	if attr.Value.Kind() == slog.KindString {
+		if value := attr.Value.String(); jwtPattern.MatchString(value) {
+			return slog.String(attr.Key, jwtPattern.ReplaceAllString(value, redacted))
+		}
+	}
+	return attr
+}
+
+// entry holds a request's logger; it is shared by pointer so attributes added deep in
+// the handler chain also reach the access log written by the outer middleware
+type entry struct {
+	logger *slog.Logger
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying logger as the request logger
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, &entry{logger: logger})
+}
+
+// FromContext returns the request logger in ctx, or the default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if e, ok := ctx.Value(contextKey{}).(*entry); ok {
+		return e.logger
+	}
+	return slog.Default()
+}
+
+// AddAttrs adds attributes to the request logger in ctx for everything logged afterwards.
+// It does nothing when ctx carries no request logger.
+func AddAttrs(ctx context.Context, args ...any) {
+	if e, ok := ctx.Value(contextKey{}).(*entry); ok {
+		e.logger = e.logger.With(args...)
+	}
+}
@@ -0,0 +1,106 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"azyqs-auth-systems/config"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Mailer sends email messages
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+	// Ping reports whether messages can currently be handed off
+	Ping(ctx context.Context) error
+}
+
+// password is used by every SMTP login so a rotated password applies without a restart
+var password atomic.Value
+
+// SetPassword replaces the password SMTP logins authenticate with
+func SetPassword(p string) {
+	password.Store(p)
+}
+
+// New returns the Mailer selected by the mail driver setting
+func New(cfg config.MailConfig) Mailer {
+	if cfg.Driver == config.MailDriverSMTP {
+		SetPassword(cfg.Password)
+		return &SMTPMailer{cfg: cfg}
+	}
+	return &LogMailer{from: cfg.From}
+}
+
+// LogMailer writes messages to the log instead of sending them; meant for development
+type LogMailer struct {
+	from string
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	slog.Info("mail", "from", m.from, "to", strings.Join(msg.To, ", "), "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
+
+func (m *LogMailer) Ping(ctx context.Context) error {
+	return nil
+}
+
+// SMTPMailer delivers messages through an SMTP relay, upgrading to TLS when offered
+type SMTPMailer struct {
+	cfg config.MailConfig
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		current, _ := password.Load().(string)
+		auth = smtp.PlainAuth("", m.cfg.Username, current, m.cfg.Host)
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", m.cfg.From)
+	fmt.Fprintf(&body, "To: %s\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&body, "Subject: %s\r\n", msg.Subject)
+	body.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+	body.WriteString(msg.Body)
+
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	return smtp.SendMail(addr, auth, m.cfg.From, msg.To, []byte(body.String()))
+}
+
+// Ping connects to the relay and waits for its greeting
+func (m *SMTPMailer) Ping(ctx context.Context) error {
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	return client.Quit()
+}
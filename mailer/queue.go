@@ -0,0 +1,90 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+)
+
+// Queue errors
+var (
+	ErrQueueFull   = errors.New("mail_queue_full")
+	ErrQueueClosed = errors.New("mail_queue_closed")
+)
+
+// Queue sends messages from a background worker so requests never wait on the mail server.
+// Close stops accepting messages and flushes the ones already queued.
+type Queue struct {
+	mailer   Mailer
+	messages chan Message
+	done     chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewQueue starts a worker that sends up to size buffered messages through m
+func NewQueue(m Mailer, size int) *Queue {
+	q := &Queue{
+		mailer:   m,
+		messages: make(chan Message, size),
+		done:     make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// Send queues msg without blocking; delivery failures are only logged
+func (q *Queue) Send(ctx context.Context, msg Message) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	select {
+	case q.messages <- msg:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Ping fails once the queue is closed and otherwise checks the underlying mailer
+func (q *Queue) Ping(ctx context.Context) error {
+	q.mu.RLock()
+	closed := q.closed
+	q.mu.RUnlock()
+	if closed {
+		return ErrQueueClosed
+	}
+	return q.mailer.Ping(ctx)
+}
+
+// Close stops accepting messages and waits until the queued ones are sent or ctx ends
+func (q *Queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.messages)
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) run() {
+	defer close(q.done)
+	for msg := range q.messages {
+		// Messages are sent to completion even during shutdown; Close bounds the wait
+		if err := q.mailer.Send(context.Background(), msg); err != nil {
+			slog.Error("mail delivery failed", "to", msg.To, "subject", msg.Subject, "error", err)
+		}
+	}
+}
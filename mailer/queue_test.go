@@ -0,0 +1,82 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingMailer collects sent messages, optionally waiting on release first
+type recordingMailer struct {
+	mu      sync.Mutex
+	sent    []Message
+	release chan struct{}
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg Message) error {
+	if m.release != nil {
+		<-m.release
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func (m *recordingMailer) Ping(ctx context.Context) error {
+	return nil
+}
+
+func TestQueueFlushesOnClose(t *testing.T) {
+	m := &recordingMailer{}
+	q := NewQueue(m, 10)
+
+	for _, subject := range []string{"one", "two", "three"} {
+		if err := q.Send(context.Background(), Message{To: []string{"a@example.com"}, Subject: subject}); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	if err := q.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if len(m.sent) != 3 {
+		t.Fatalf("expected every queued message to be sent before Close returns, got %d", len(m.sent))
+	}
+
+	if err := q.Ping(context.Background()); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("expected Ping to fail after Close, got %v", err)
+	}
+	if err := q.Send(context.Background(), Message{}); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("expected %v after Close, got %v", ErrQueueClosed, err)
+	}
+	if err := q.Close(context.Background()); err != nil {
+		t.Errorf("expected a second Close to succeed, got %v", err)
+	}
+}
+
+func TestQueueFullAndCloseTimeout(t *testing.T) {
+	m := &recordingMailer{release: make(chan struct{})}
+	q := NewQueue(m, 1)
+
+	// The worker holds the first message, the buffer holds the second
+	q.Send(context.Background(), Message{Subject: "in flight"})
+	deadline := time.Now().Add(time.Second)
+	for len(q.messages) != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := q.Send(context.Background(), Message{Subject: "buffered"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if err := q.Send(context.Background(), Message{Subject: "dropped"}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected %v, got %v", ErrQueueFull, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected Close to give up when its context ends, got %v", err)
+	}
+	close(m.release)
+}
@@ -4,16 +4,23 @@ import (
 	"flag"
 	"fmt"
 	"log"
-	"net"
-	"net/http"
+	"log/slog"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
+	"azyqs-auth-systems/breach"
 	"azyqs-auth-systems/config"
-	"azyqs-auth-systems/models"
-	"azyqs-auth-systems/routes"
+	"azyqs-auth-systems/controllers"
+	"azyqs-auth-systems/mailer"
+	"azyqs-auth-systems/repositories"
+	"azyqs-auth-systems/services"
+	"azyqs-auth-systems/utils"
+	"azyqs-auth-systems/validators"
 
-	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
+	"gorm.io/gorm"
 )
 
 // Standard response structure
@@ -23,54 +30,178 @@ type Response struct {
 	Data    interface{} `json:"data,omitempty"`
 }
 
-func isPortAvailable(port string) bool {
-	ln, err := net.Listen("tcp", ":"+port)
-	if err != nil {
-		return false
-	}
-	_ = ln.Close()
-	return true
-}
+const usage = `usage: azyqs-auth-systems <command> [arguments]
 
-func main() {
-	log.Printf("Starting server...")
+commands:
+  serve [-port PORT]                          run the HTTP server (default)
+  migrate up | down [steps] | status          manage the database schema
+  user create -username U -name N -email E [-password P] [-role R]
+  user set-password [-password P] [-temporary] <username>
+                                              replace a password and revoke sessions
+  user expire-password <username>             require a password change at the next login
+  user disable <username>                     block logins and revoke sessions
+  user grant-role <username> <role>           set a user's role (user, admin)
+  keys rotate [-file PATH] [-keep N]          add a new active JWT signing key; send
+                                              SIGHUP or restart servers to use it
+  sessions revoke <username>                  invalidate every token of a user
+  audit verify                                verify the audit log hash chain
+  breach build -in CORPUS -out FILTER [-format sha1|plain] [-rate R]
+                                              build the breached password filter
+
+Every command except "breach build" accepts -config FILE and one flag per
+configuration setting (e.g. -database.max_open_conns 10); run
+"serve -h" to list them. Secret settings (database.url, database.password,
+auth.jwt_secret, mail.password) also accept file://PATH and env://NAME
+references. On SIGHUP "serve" re-reads auth.jwt_secret, the auth.jwt_keys_file
+keys, database.password and mail.password; a changed database.url needs a
+restart. Webhook signing secrets are not configuration: each endpoint's secret
+is stored in the database; rotate it by re-creating the endpoint through
+/admin/webhooks. Passwords not given with -password are read from standard input.`
 
+func main() {
 	// Load .env file if available
-	log.Printf("Loading environment variables...")
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found, make sure environment variables are set")
 	}
 
-	// Command-line flag for server port (default 8080)
-	log.Printf("Parsing command-line arguments...")
-	port := flag.String("port", "", "Server port (default from .env or 8080)")
-	flag.Parse()
-
-	// Determine final port
-	log.Printf("Checking server port...")
-	finalPort := *port
-	if finalPort == "" {
-		finalPort = os.Getenv("PORT")
-		if finalPort == "" {
-			finalPort = "8080"
-		}
+	args := os.Args[1:]
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		runServe(args)
+		return
 	}
 
-	// Check if the port is already in use
-	if !isPortAvailable(finalPort) {
-		log.Fatalf("Port %s is already in use. Please choose a different port.", finalPort)
+	switch args[0] {
+	case "serve":
+		runServe(args[1:])
+	case "migrate":
+		runMigrate(args[1:])
+	case "user":
+		runUser(args[1:])
+	case "keys":
+		runKeys(args[1:])
+	case "sessions":
+		runSessions(args[1:])
+	case "audit":
+		runAudit(args[1:])
+	case "breach":
+		runBreach(args[1:])
+	case "help", "-h", "--help":
+		fmt.Println(usage)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
 	}
+}
 
-	// Initialize database connection
-	log.Printf("Initializing database connection...")
-	db := config.InitDB()
-	db.AutoMigrate(&models.User{})
+// commandFlags returns a flag set for a command with every configuration flag registered
+func commandFlags(name string) (*flag.FlagSet, *config.Loader) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	return flags, config.NewLoader(flags)
+}
 
-	// Initialize router
-	log.Printf("Initializing router...")
-	router := mux.NewRouter()
-	routes.RegisterRoutes(router)
+// loadConfig resolves the configuration, exiting with every problem listed if it is invalid,
+// and applies the settings read by package-level state
+func loadConfig(loader *config.Loader) *config.Config {
+	cfg := resolveConfig(loader)
+	utils.Hasher = passwordHasher(cfg.Auth)
+	utils.AccessTokenTTL = cfg.Auth.AccessTokenTTL
+	utils.PasswordChangeTTL = cfg.Auth.PasswordChangeTTL
+	utils.ElevatedTokenTTL = cfg.Auth.ElevatedTokenTTL
+	utils.RecentAuthMaxAge = cfg.Auth.RecentAuthMaxAge
+	services.EmailChangeTTL = cfg.Auth.EmailChangeTTL
+	services.LinkBaseURL = cfg.Mail.LinkBaseURL
+	validators.Policy = validators.PasswordPolicy(cfg.PasswordPolicy)
+	validators.MaxPasswordBytes = 0
+	if cfg.Auth.PasswordHash == config.PasswordHashBcrypt {
+		validators.MaxPasswordBytes = utils.BcryptMaxPasswordBytes
+	}
+	if err := applySecrets(cfg); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	return cfg
+}
 
-	log.Printf("Server is up and running on port %s...\n", finalPort)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%s", finalPort), router))
+// resolveConfig resolves the configuration without applying it, exiting with every problem
+// listed if it is invalid
+func resolveConfig(loader *config.Loader) *config.Config {
+	cfg, err := loader.Load()
+	if err != nil {
+		log.Fatalf("Error: invalid configuration:\n  - %s", strings.ReplaceAll(err.Error(), "\n", "\n  - "))
+	}
+	return cfg
+}
+
+// passwordHasher returns the hasher for new password hashes selected by cfg
+func passwordHasher(cfg config.AuthConfig) utils.PasswordHasher {
+	if cfg.PasswordHash == config.PasswordHashBcrypt {
+		return utils.BcryptHasher{Cost: cfg.BcryptCost}
+	}
+	return utils.Argon2idHasher{
+		Memory:      uint32(cfg.Argon2Memory),
+		Iterations:  uint32(cfg.Argon2Iterations),
+		Parallelism: uint8(cfg.Argon2Parallelism),
+	}
+}
+
+// applySecrets installs the signing keys, database password and SMTP password from cfg
+func applySecrets(cfg *config.Config) error {
+	if err := utils.Keys.Reload([]byte(cfg.Auth.JWTSecret), cfg.Auth.JWTKeysFile); err != nil {
+		return fmt.Errorf("failed to load signing keys from %s: %w", cfg.Auth.JWTKeysFile, err)
+	}
+	if cfg.Auth.JWTKeysFile != "" {
+		slog.Info("loaded signing keys", "count", utils.Keys.Len(), "file", cfg.Auth.JWTKeysFile)
+	}
+	config.SetDatabasePassword(cfg.Database.Password)
+	mailer.SetPassword(cfg.Mail.Password)
+	return nil
+}
+
+// loadBreachedPasswords installs the breached password filter, if configured, so new
+// passwords found in it are rejected
+func loadBreachedPasswords(cfg *config.Config) error {
+	if cfg.Auth.BreachedPasswordsFile == "" {
+		return nil
+	}
+	filter, err := breach.Load(cfg.Auth.BreachedPasswordsFile)
+	if err != nil {
+		return fmt.Errorf("failed to load breached passwords from %s: %w", cfg.Auth.BreachedPasswordsFile, err)
+	}
+	validators.Breached = filter
+	return nil
+}
+
+// reloadSecretsOnHangup re-reads the configuration on SIGHUP and applies rotated secrets.
+// Other settings only take effect after a restart.
+func reloadSecretsOnHangup(loader *config.Loader, current *config.Config) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	for range hangup {
+		slog.Info("reloading secrets")
+		cfg, err := loader.Load()
+		if err != nil {
+			slog.Error("secrets not reloaded, invalid configuration", "error", err)
+			continue
+		}
+		if err := applySecrets(cfg); err != nil {
+			slog.Error("secrets not reloaded", "error", err)
+			continue
+		}
+		if cfg.Database.URL != current.Database.URL {
+			slog.Warn("database.url changed; restart to connect to the new database")
+		}
+		slog.Info("secrets reloaded")
+	}
+}
+
+// newHandler wires repositories and services for db, sending email through mail
+func newHandler(db *gorm.DB, mail mailer.Mailer) *controllers.Handler {
+	store := repositories.NewGormStore(db)
+	audit := services.NewAuditService(store, utils.Keys)
+	return &controllers.Handler{
+		Auth:     services.NewAuthService(store, audit),
+		Users:    services.NewUserService(store, audit, mail),
+		Audit:    audit,
+		Webhooks: services.NewWebhookService(store),
+	}
 }
@@ -0,0 +1,43 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	serviceErrors "azyqs-auth-systems/errors"
+	"azyqs-auth-systems/models"
+
+	"github.com/google/uuid"
+)
+
+// UserLookup loads a user by ID
+type UserLookup interface {
+	GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error)
+}
+
+// RequireAdmin only lets users with the admin role through; it must run after JwtAuthentication
+func RequireAdmin(users UserLookup) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userIDStr, ok := r.Context().Value(UserIDKey).(string)
+			if !ok {
+				writeError(w, r, serviceErrors.ErrUserIDNotFound)
+				return
+			}
+
+			userID, err := uuid.Parse(userIDStr)
+			if err != nil {
+				writeError(w, r, serviceErrors.ErrInvalidUserID)
+				return
+			}
+
+			user, err := users.GetUserByID(r.Context(), userID)
+			if err != nil || user.Role != models.RoleAdmin {
+				writeError(w, r, serviceErrors.ErrForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
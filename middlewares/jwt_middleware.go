@@ -3,25 +3,35 @@ package middlewares
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"slices"
 	"strings"
 
+	serviceErrors "azyqs-auth-systems/errors"
+	"azyqs-auth-systems/i18n"
+	"azyqs-auth-systems/logging"
+	"azyqs-auth-systems/metrics"
 	"azyqs-auth-systems/utils"
 )
 
 // ErrorResponse defines the standard error response structure
 type ErrorResponse struct {
-	Status  string `json:"status"`
-	Message string `json:"message"`
+	Status           string `json:"status"`
+	Message          string `json:"message"`
+	LocalizedMessage string `json:"localized_message"`
 }
 
-// writeJSON helps write a JSON response with a consistent format
-func writeJSON(w http.ResponseWriter, statusCode int, status, message string) {
+// writeError writes the API error err maps to
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr, _ := serviceErrors.ToAPIError(err)
+	localized := i18n.Localize(w, r, apiErr.Code)
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
+	w.WriteHeader(apiErr.Status)
 	json.NewEncoder(w).Encode(ErrorResponse{
-		Status:  status,
-		Message: message,
+		Status:           "error",
+		Message:          apiErr.Code,
+		LocalizedMessage: localized,
 	})
 }
 
@@ -30,39 +40,72 @@ type contextKey string
 
 const UserIDKey contextKey = "userID"
 
-// JwtAuthentication validates the JWT token in the Authorization header
-func JwtAuthentication(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		tokenHeader := r.Header.Get("Authorization")
-
-		if tokenHeader == "" {
-			writeJSON(w, http.StatusForbidden, "error", "token_not_found")
-			return
-		}
-
-		splitted := strings.Split(tokenHeader, " ")
-		if len(splitted) != 2 {
-			writeJSON(w, http.StatusForbidden, "error", "token_invalid_format")
-			return
-		}
-
-		tokenPart := splitted[1]
-		userID, err := utils.ValidateJWT(tokenPart)
-		if err != nil {
-			switch err.Error() {
-			case "token_expired":
-				writeJSON(w, http.StatusForbidden, "error", "token_expired")
-			case "token_invalid_signature":
-				writeJSON(w, http.StatusForbidden, "error", "token_invalid_signature")
-			default:
-				writeJSON(w, http.StatusForbidden, "error", "token_invalid")
+// AuthTimeKey holds the time.Time the user last proved their credentials, from the token
+const AuthTimeKey contextKey = "authTime"
+
+// ElevatedKey holds whether the token was issued by re-authentication
+const ElevatedKey contextKey = "elevated"
+
+// JwtAuthentication validates the JWT token in the Authorization header and rejects
+// tokens of disabled users or tokens issued before the user's sessions were revoked.
+// Restricted tokens are only accepted when their scope is listed in scopes.
+func JwtAuthentication(users UserLookup, scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenHeader := r.Header.Get("Authorization")
+
+			if tokenHeader == "" {
+				writeError(w, r, serviceErrors.ErrTokenNotFound)
+				return
 			}
-			return
-		}
 
-		userIDStr := userID.String() // Pastikan dikonversi ke string sebelum disimpan
+			splitted := strings.Split(tokenHeader, " ")
+			if len(splitted) != 2 {
+				writeError(w, r, serviceErrors.ErrTokenInvalidFormat)
+				return
+			}
 
-		ctx := context.WithValue(r.Context(), UserIDKey, userIDStr)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+			tokenPart := splitted[1]
+			claims, err := utils.ValidateJWT(tokenPart)
+			if err != nil {
+				metrics.TokenValidationFailures.WithLabelValues(err.Error()).Inc()
+				// Only expiry is worth telling apart; every other failure is just an invalid token
+				if errors.Is(err, utils.ErrTokenExpired) {
+					writeError(w, r, serviceErrors.ErrTokenExpired)
+				} else {
+					writeError(w, r, serviceErrors.ErrTokenInvalid)
+				}
+				return
+			}
+
+			// The only restricted tokens are issued to users who must change their password
+			if claims.Scope != "" && !slices.Contains(scopes, claims.Scope) {
+				writeError(w, r, serviceErrors.ErrPasswordChangeRequired)
+				return
+			}
+
+			user, err := users.GetUserByID(r.Context(), claims.UserID)
+			switch {
+			case errors.Is(err, serviceErrors.ErrUserNotFound):
+				writeError(w, r, serviceErrors.ErrTokenRevoked)
+				return
+			case err != nil:
+				// A failed lookup says nothing about the token, so it must not look like a revocation
+				logging.FromContext(r.Context()).Error("failed to look up token user", "user_id", claims.UserID, "error", err)
+				writeError(w, r, serviceErrors.ErrInternalServer)
+				return
+			case user.Disabled || user.TokenVersion != claims.TokenVersion:
+				writeError(w, r, serviceErrors.ErrTokenRevoked)
+				return
+			}
+
+			userIDStr := claims.UserID.String() // Pastikan dikonversi ke string sebelum disimpan
+
+			logging.AddAttrs(r.Context(), "user_id", userIDStr)
+			ctx := context.WithValue(r.Context(), UserIDKey, userIDStr)
+			ctx = context.WithValue(ctx, AuthTimeKey, claims.AuthTime)
+			ctx = context.WithValue(ctx, ElevatedKey, claims.Elevated)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
 }
@@ -0,0 +1,236 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"azyqs-auth-systems/config"
+	"azyqs-auth-systems/logging"
+
+	"github.com/google/uuid"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func TestCORS(t *testing.T) {
+	handler := CORS(config.CORSConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Authorization"},
+		MaxAge:         time.Minute,
+	})(okHandler)
+
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		preflight   bool
+		wantCode    int
+		wantAllowed string
+	}{
+		{"allowed origin", http.MethodGet, "https://app.example.com", false, http.StatusOK, "https://app.example.com"},
+		{"unknown origin", http.MethodGet, "https://evil.example.com", false, http.StatusOK, ""},
+		{"no origin", http.MethodGet, "", false, http.StatusOK, ""},
+		{"preflight", http.MethodOptions, "https://app.example.com", true, http.StatusNoContent, "https://app.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("expected status %d, got %d", tt.wantCode, rec.Code)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllowed {
+				t.Errorf("expected Access-Control-Allow-Origin %q, got %q", tt.wantAllowed, got)
+			}
+			if tt.preflight && rec.Header().Get("Access-Control-Max-Age") != "60" {
+				t.Errorf("expected Access-Control-Max-Age 60, got %q", rec.Header().Get("Access-Control-Max-Age"))
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	handler := RateLimit(config.RateLimitConfig{Enabled: true, RequestsPerMinute: 1, Burst: 2})(okHandler)
+
+	send := func(addr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = addr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		if rec := send("10.0.0.1:1234"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d within burst: expected 200, got %d", i+1, rec.Code)
+		}
+	}
+
+	rec := send("10.0.0.1:5678")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 once the burst is spent, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("expected a Retry-After header")
+	}
+
+	if rec := send("10.0.0.2:1234"); rec.Code != http.StatusOK {
+		t.Errorf("expected other clients to be unaffected, got %d", rec.Code)
+	}
+
+	// Behind a trusted proxy each forwarded client gets its own bucket
+	proxied := RateLimit(config.RateLimitConfig{Enabled: true, RequestsPerMinute: 1, Burst: 1, TrustedProxies: []string{"10.1.0.0/16"}})(okHandler)
+	forward := func(client string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.1.0.5:443"
+		req.Header.Set("X-Forwarded-For", client)
+		rec := httptest.NewRecorder()
+		proxied.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if forward("203.0.113.7") != http.StatusOK || forward("203.0.113.7") != http.StatusTooManyRequests {
+		t.Error("expected a forwarded client to be limited on its own")
+	}
+	if code := forward("203.0.113.8"); code != http.StatusOK {
+		t.Errorf("expected other forwarded clients to be unaffected, got %d", code)
+	}
+
+	disabled := RateLimit(config.RateLimitConfig{Enabled: false})(okHandler)
+	for i := 0; i < 5; i++ {
+		rec := httptest.NewRecorder()
+		disabled.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected disabled limiter to pass requests, got %d", rec.Code)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16"), netip.MustParsePrefix("192.0.2.1/32")}
+
+	tests := []struct {
+		name      string
+		peer      string
+		forwarded []string
+		trusted   []netip.Prefix
+		want      string
+	}{
+		{"no trusted proxies", "10.1.0.5:443", []string{"203.0.113.7"}, nil, "10.1.0.5"},
+		{"untrusted peer cannot forge", "198.51.100.1:443", []string{"203.0.113.7"}, trusted, "198.51.100.1"},
+		{"trusted peer", "10.1.0.5:443", []string{"203.0.113.7"}, trusted, "203.0.113.7"},
+		{"chain of trusted proxies", "10.1.0.5:443", []string{"203.0.113.7, 192.0.2.1"}, trusted, "203.0.113.7"},
+		{"forged entries left of the client", "10.1.0.5:443", []string{"198.51.100.9, 203.0.113.7"}, trusted, "203.0.113.7"},
+		{"repeated headers", "10.1.0.5:443", []string{"203.0.113.7", "192.0.2.1"}, trusted, "203.0.113.7"},
+		{"garbage stops the walk", "10.1.0.5:443", []string{"203.0.113.7, nonsense"}, trusted, "10.1.0.5"},
+		{"no header", "10.1.0.5:443", nil, trusted, "10.1.0.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.peer
+			for _, header := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", header)
+			}
+			if got := clientIP(req, tt.trusted); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	handler := RequestID(slog.New(slog.NewTextHandler(&buf, nil)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logging.FromContext(r.Context()).Info("handled")
+	}))
+
+	tests := []struct {
+		name     string
+		header   string
+		generate bool
+	}{
+		{"accepted", "req-123:abc.DEF_4", false},
+		{"missing", "", true},
+		{"invalid characters", "bad id\n", true},
+		{"too long", strings.Repeat("a", 129), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			id := rec.Header().Get(RequestIDHeader)
+			if tt.generate {
+				if _, err := uuid.Parse(id); err != nil {
+					t.Fatalf("expected a generated UUID, got %q", id)
+				}
+			} else if id != tt.header {
+				t.Fatalf("expected %q to be echoed, got %q", tt.header, id)
+			}
+			if !strings.Contains(buf.String(), "request_id="+id) {
+				t.Fatalf("expected the request logger to carry the ID, got %q", buf.String())
+			}
+		})
+	}
+}
+
+func TestRequireRecentAuth(t *testing.T) {
+	handler := RequireRecentAuth(5 * time.Minute)(okHandler)
+
+	tests := []struct {
+		name     string
+		authTime interface{}
+		elevated bool
+		wantCode int
+	}{
+		{"recent", time.Now().Add(-time.Minute), true, http.StatusOK},
+		{"recent login", time.Now().Add(-time.Minute), false, http.StatusUnauthorized},
+		{"too old", time.Now().Add(-time.Hour), true, http.StatusUnauthorized},
+		{"unknown", time.Time{}, true, http.StatusUnauthorized},
+		{"not authenticated", nil, false, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/", nil)
+			if tt.authTime != nil {
+				ctx := context.WithValue(req.Context(), AuthTimeKey, tt.authTime)
+				req = req.WithContext(context.WithValue(ctx, ElevatedKey, tt.elevated))
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("expected status %d, got %d", tt.wantCode, rec.Code)
+			}
+			if tt.wantCode != http.StatusOK && !strings.Contains(rec.Body.String(), "reauthentication_required") {
+				t.Errorf("expected reauthentication_required, got %s", rec.Body.String())
+			}
+		})
+	}
+}
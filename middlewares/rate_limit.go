@@ -0,0 +1,125 @@
+package middlewares
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"azyqs-auth-systems/config"
+	serviceErrors "azyqs-auth-systems/errors"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimiterIdleTTL is how long an idle client's limiter is kept
+const rateLimiterIdleTTL = 10 * time.Minute
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// RateLimit limits requests per client IP with a token bucket. Behind a trusted proxy the
+// client IP is taken from X-Forwarded-For, otherwise every client would share the proxy's bucket.
+func RateLimit(cfg config.RateLimitConfig) func(http.Handler) http.Handler {
+	if !cfg.Enabled {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	// The configuration is validated, so entries that do not parse never reach here
+	var trusted []netip.Prefix
+	for _, proxy := range cfg.TrustedProxies {
+		if prefix, err := config.ParseTrustedProxy(proxy); err == nil {
+			trusted = append(trusted, prefix)
+		}
+	}
+
+	var (
+		mu        sync.Mutex
+		clients   = map[string]*clientLimiter{}
+		lastSweep = time.Now()
+		limit     = rate.Limit(float64(cfg.RequestsPerMinute) / 60)
+	)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := clientIP(r, trusted)
+
+			now := time.Now()
+			mu.Lock()
+			if now.Sub(lastSweep) > rateLimiterIdleTTL {
+				for key, client := range clients {
+					if now.Sub(client.lastSeen) > rateLimiterIdleTTL {
+						delete(clients, key)
+					}
+				}
+				lastSweep = now
+			}
+			client, ok := clients[ip]
+			if !ok {
+				client = &clientLimiter{limiter: rate.NewLimiter(limit, cfg.Burst)}
+				clients[ip] = client
+			}
+			client.lastSeen = now
+			reservation := client.limiter.ReserveN(now, 1)
+			delay := reservation.DelayFrom(now)
+			if delay > 0 {
+				reservation.CancelAt(now)
+			}
+			mu.Unlock()
+
+			if delay > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+				writeError(w, r, serviceErrors.ErrRateLimited)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP returns the IP of the client behind r. X-Forwarded-For is read right to left, each
+// entry added by the hop before it, and only while that hop is a trusted proxy; the first
+// untrusted address is the client, since anything left of it may be forged.
+func clientIP(r *http.Request, trusted []netip.Prefix) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if len(trusted) == 0 {
+		return ip
+	}
+
+	isTrusted := func(raw string) bool {
+		addr, err := netip.ParseAddr(raw)
+		if err != nil {
+			return false
+		}
+		addr = addr.Unmap()
+		for _, prefix := range trusted {
+			if prefix.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0 && isTrusted(ip); i-- {
+		hop := strings.TrimSpace(hops[i])
+		if _, err := netip.ParseAddr(hop); err != nil {
+			break
+		}
+		ip = hop
+	}
+	return ip
+}
@@ -0,0 +1,26 @@
+package middlewares
+
+import (
+	"net/http"
+	"time"
+
+	serviceErrors "azyqs-auth-systems/errors"
+)
+
+// RequireRecentAuth only lets requests through with an elevated token from
+// /auth/reauthenticate, issued when the user entered their password at most maxAge ago.
+// Login tokens are never enough, however fresh. It must run after JwtAuthentication.
+func RequireRecentAuth(maxAge time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			elevated, _ := r.Context().Value(ElevatedKey).(bool)
+			authTime, ok := r.Context().Value(AuthTimeKey).(time.Time)
+			if !elevated || !ok || authTime.IsZero() || time.Since(authTime) > maxAge {
+				writeError(w, r, serviceErrors.ErrRecentAuthRequired)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
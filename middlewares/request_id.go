@@ -0,0 +1,40 @@
+package middlewares
+
+import (
+	"log/slog"
+	"net/http"
+	"regexp"
+
+	"azyqs-auth-systems/logging"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the request ID in both directions
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID limits accepted IDs to short, log-safe values
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID accepts the caller's X-Request-ID or generates one, echoes it in the response
+// and stores a request logger carrying it (and the trace ID, if any) in the context
+func RequestID(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID.MatchString(id) {
+				id = uuid.NewString()
+			}
+			w.Header().Set(RequestIDHeader, id)
+
+			requestLogger := logger.With("request_id", id)
+			if span := trace.SpanContextFromContext(r.Context()); span.IsValid() {
+				requestLogger = requestLogger.With("trace_id", span.TraceID().String())
+			}
+
+			ctx := logging.NewContext(r.Context(), requestLogger)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"azyqs-auth-systems/config"
+	"azyqs-auth-systems/migrations"
+)
+
+const migrateUsage = "usage: migrate [-config FILE] up | down [steps] | status"
+
+// runMigrate handles the "migrate up|down|status" subcommand
+func runMigrate(args []string) {
+	flags, loader := commandFlags("migrate")
+	flags.Parse(args)
+	args = flags.Args()
+	if len(args) == 0 {
+		log.Fatal(migrateUsage)
+	}
+	cfg := loadConfig(loader)
+
+	migrator, err := migrations.New(config.InitDB(cfg.Database))
+	if err != nil {
+		log.Fatalf("Error: failed to load migrations: %v", err)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			log.Printf("Applied %04d_%s", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		log.Printf("%d migration(s) applied", len(applied))
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				log.Fatal(migrateUsage)
+			}
+		}
+		reverted, err := migrator.Down(steps)
+		for _, migration := range reverted {
+			log.Printf("Reverted %04d_%s", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		log.Printf("%d migration(s) reverted", len(reverted))
+
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(os.Stdout, "%04d  %-28s %s\n", status.Version, status.Name, state)
+		}
+
+	default:
+		log.Fatal(migrateUsage)
+	}
+}
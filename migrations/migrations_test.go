@@ -0,0 +1,296 @@
+package migrations
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"azyqs-auth-systems/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newSQLiteDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	return db
+}
+
+var testFS = fstest.MapFS{
+	"0001_create_widgets.up.sql":     {Data: []byte("CREATE TABLE widgets (id integer PRIMARY KEY, name text);")},
+	"0001_create_widgets.down.sql":   {Data: []byte("DROP TABLE widgets;")},
+	"0002_add_widget_color.up.sql":   {Data: []byte("ALTER TABLE widgets ADD COLUMN color text;\nCREATE INDEX idx_widgets_color ON widgets (color);")},
+	"0002_add_widget_color.down.sql": {Data: []byte("DROP INDEX idx_widgets_color;\nALTER TABLE widgets DROP COLUMN color;")},
+}
+
+func TestEmbeddedMigrationsLoad(t *testing.T) {
+	migrator, err := New(newSQLiteDB(t))
+	if err != nil {
+		t.Fatalf("embedded migrations failed to load: %v", err)
+	}
+	for i, migration := range migrator.migrations {
+		if migration.Version != int64(i+1) {
+			t.Fatalf("expected contiguous versions, got %d at position %d", migration.Version, i)
+		}
+	}
+}
+
+func TestUpDownStatus(t *testing.T) {
+	db := newSQLiteDB(t)
+	migrator, err := NewFromFS(db, testFS)
+	if err != nil {
+		t.Fatalf("NewFromFS failed: %v", err)
+	}
+
+	applied, err := migrator.Up()
+	if err != nil || len(applied) != 2 {
+		t.Fatalf("expected 2 migrations applied, got %d (%v)", len(applied), err)
+	}
+	if err := db.Exec("INSERT INTO widgets (name, color) VALUES ('a', 'red')").Error; err != nil {
+		t.Fatalf("schema was not migrated: %v", err)
+	}
+
+	applied, err = migrator.Up()
+	if err != nil || len(applied) != 0 {
+		t.Fatalf("expected Up to be idempotent, got %d (%v)", len(applied), err)
+	}
+
+	reverted, err := migrator.Down(1)
+	if err != nil || len(reverted) != 1 || reverted[0].Version != 2 {
+		t.Fatalf("expected migration 2 to be reverted, got %+v (%v)", reverted, err)
+	}
+
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if len(statuses) != 2 || !statuses[0].Applied || statuses[0].AppliedAt == nil || statuses[1].Applied {
+		t.Fatalf("unexpected status: %+v", statuses)
+	}
+
+	if _, err := migrator.Down(5); err != nil {
+		t.Fatalf("Down failed: %v", err)
+	}
+	if db.Migrator().HasTable("widgets") {
+		t.Fatal("expected widgets table to be dropped")
+	}
+}
+
+func TestPending(t *testing.T) {
+	migrator, err := NewFromFS(newSQLiteDB(t), testFS)
+	if err != nil {
+		t.Fatalf("NewFromFS failed: %v", err)
+	}
+
+	if _, err := migrator.Pending(); err == nil {
+		t.Fatal("expected Pending to fail before the tracking table exists")
+	}
+
+	migrator.Up()
+	migrator.Down(1)
+	pending, err := migrator.Pending()
+	if err != nil || len(pending) != 1 || pending[0].Version != 2 {
+		t.Fatalf("expected migration 2 to be pending, got %+v (%v)", pending, err)
+	}
+
+	migrator.Up()
+	if pending, err := migrator.Pending(); err != nil || len(pending) != 0 {
+		t.Fatalf("expected nothing pending, got %+v (%v)", pending, err)
+	}
+}
+
+func TestFailedMigrationIsRolledBack(t *testing.T) {
+	db := newSQLiteDB(t)
+	fsys := fstest.MapFS{
+		"0001_broken.up.sql":   {Data: []byte("CREATE TABLE gadgets (id integer);\nNOT VALID SQL;")},
+		"0001_broken.down.sql": {Data: []byte("DROP TABLE gadgets;")},
+	}
+	migrator, err := NewFromFS(db, fsys)
+	if err != nil {
+		t.Fatalf("NewFromFS failed: %v", err)
+	}
+
+	if _, err := migrator.Up(); err == nil || !strings.Contains(err.Error(), "0001_broken") {
+		t.Fatalf("expected migration 0001_broken to fail, got %v", err)
+	}
+	statuses, _ := migrator.Status()
+	if statuses[0].Applied {
+		t.Fatal("failed migration must not be recorded as applied")
+	}
+}
+
+func TestLoadRejectsInvalidFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+		want string
+	}{
+		{"missing down", fstest.MapFS{"0001_a.up.sql": {Data: []byte("SELECT 1;")}}, "missing up or down"},
+		{"bad suffix", fstest.MapFS{"0001_a.sql": {Data: []byte("SELECT 1;")}}, "expected .up.sql or .down.sql"},
+		{"no name", fstest.MapFS{"0001.up.sql": {Data: []byte("SELECT 1;")}}, "expected NNNN_name"},
+		{"bad version", fstest.MapFS{"abc_a.up.sql": {Data: []byte("SELECT 1;")}}, "invalid version"},
+		{"conflicting names", fstest.MapFS{
+			"0001_a.up.sql":   {Data: []byte("SELECT 1;")},
+			"0001_b.down.sql": {Data: []byte("SELECT 1;")},
+		}, "conflicting names"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := load(tt.fsys); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+// schemaModels are the tables the application reads and writes through GORM. The SQLite test
+// harnesses create them with AutoMigrate, so they must match what the migrations build.
+var schemaModels = []any{
+	&models.User{},
+	&models.PasswordHistory{},
+	&models.EmailChange{},
+	&models.AuditEvent{},
+	&models.AuditCheckpoint{},
+	&models.WebhookEndpoint{},
+	&models.OutboxEvent{},
+	&models.WebhookDelivery{},
+}
+
+var (
+	createTable = regexp.MustCompile(`(?is)^CREATE TABLE (?:IF NOT EXISTS )?(\w+) \((.*)\)$`)
+	dropTable   = regexp.MustCompile(`(?i)^DROP TABLE (?:IF EXISTS )?(\w+)`)
+	addColumn   = regexp.MustCompile(`(?i)^ALTER TABLE (\w+) ADD COLUMN (?:IF NOT EXISTS )?(\w+)`)
+	dropColumn  = regexp.MustCompile(`(?i)^ALTER TABLE (\w+) DROP COLUMN (?:IF EXISTS )?(\w+)`)
+)
+
+// migratedColumns replays the table and column statements of the up migrations and returns
+// the columns of every table they leave behind
+func migratedColumns(t *testing.T, migrations []Migration) map[string]map[string]bool {
+	t.Helper()
+	tables := map[string]map[string]bool{}
+	for _, migration := range migrations {
+		for _, statement := range strings.Split(migration.Up, ";") {
+			statement = strings.TrimSpace(statement)
+			if m := createTable.FindStringSubmatch(statement); m != nil {
+				tables[m[1]] = map[string]bool{}
+				for _, line := range strings.Split(m[2], "\n") {
+					if fields := strings.Fields(line); len(fields) > 0 {
+						tables[m[1]][fields[0]] = true
+					}
+				}
+			} else if m := dropTable.FindStringSubmatch(statement); m != nil {
+				delete(tables, m[1])
+			} else if m := addColumn.FindStringSubmatch(statement); m != nil {
+				if tables[m[1]] == nil {
+					t.Fatalf("%s adds column %s to unknown table %s", migration.Name, m[2], m[1])
+				}
+				tables[m[1]][m[2]] = true
+			} else if m := dropColumn.FindStringSubmatch(statement); m != nil {
+				delete(tables[m[1]], m[2])
+			}
+		}
+	}
+	return tables
+}
+
+// checkModelsMatch fails t for every model column missing from tables and every table
+// column no model maps
+func checkModelsMatch(t *testing.T, db *gorm.DB, tables map[string]map[string]bool) {
+	t.Helper()
+	for _, model := range schemaModels {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatalf("failed to parse %T: %v", model, err)
+		}
+		columns, ok := tables[stmt.Schema.Table]
+		if !ok {
+			t.Errorf("no migration creates table %s for %T", stmt.Schema.Table, model)
+			continue
+		}
+		for _, column := range stmt.Schema.DBNames {
+			if !columns[column] {
+				t.Errorf("%T maps column %s.%s, which no migration creates", model, stmt.Schema.Table, column)
+			}
+		}
+		for column := range columns {
+			if stmt.Schema.LookUpField(column) == nil {
+				t.Errorf("column %s.%s is not mapped by %T", stmt.Schema.Table, column, model)
+			}
+		}
+	}
+}
+
+func TestModelsMatchEmbeddedMigrations(t *testing.T) {
+	db := newSQLiteDB(t)
+	migrator, err := New(db)
+	if err != nil {
+		t.Fatalf("embedded migrations failed to load: %v", err)
+	}
+	checkModelsMatch(t, db, migratedColumns(t, migrator.migrations))
+}
+
+// TestEmbeddedMigrationsPostgres runs the embedded migrations against the Postgres database
+// named by TEST_DATABASE_URL. Every table in it is dropped, so use a disposable database.
+func TestEmbeddedMigrationsPostgres(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("failed to open postgres: %v", err)
+	}
+	migrator, err := New(db)
+	if err != nil {
+		t.Fatalf("embedded migrations failed to load: %v", err)
+	}
+	if _, err := migrator.Down(len(migrator.migrations)); err != nil {
+		t.Fatalf("failed to reset the database: %v", err)
+	}
+
+	applied, err := migrator.Up()
+	if err != nil || len(applied) != len(migrator.migrations) {
+		t.Fatalf("expected %d migrations applied, got %d (%v)", len(migrator.migrations), len(applied), err)
+	}
+	tables := map[string]map[string]bool{}
+	for _, model := range schemaModels {
+		columnTypes, err := db.Migrator().ColumnTypes(model)
+		if err != nil {
+			t.Fatalf("failed to read the columns of %T: %v", model, err)
+		}
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatalf("failed to parse %T: %v", model, err)
+		}
+		tables[stmt.Schema.Table] = map[string]bool{}
+		for _, column := range columnTypes {
+			tables[stmt.Schema.Table][column.Name()] = true
+		}
+	}
+	checkModelsMatch(t, db, tables)
+
+	reverted, err := migrator.Down(len(migrator.migrations))
+	if err != nil || len(reverted) != len(migrator.migrations) {
+		t.Fatalf("expected %d migrations reverted, got %d (%v)", len(migrator.migrations), len(reverted), err)
+	}
+	for _, model := range schemaModels {
+		if db.Migrator().HasTable(model) {
+			t.Errorf("expected the table of %T to be dropped", model)
+		}
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("failed to migrate up again after a full rollback: %v", err)
+	}
+}
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS users (
+    id         uuid PRIMARY KEY,
+    username   text,
+    name       text,
+    email      text,
+    password   text,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (username);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+    sequence   bigint PRIMARY KEY,
+    action     text,
+    user_id    uuid,
+    metadata   text,
+    prev_hash  text,
+    hash       text,
+    created_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events (action);
+CREATE INDEX IF NOT EXISTS idx_audit_events_user_id ON audit_events (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_events_hash ON audit_events (hash);
+
+CREATE TABLE IF NOT EXISTS audit_checkpoints (
+    id         bigserial PRIMARY KEY,
+    sequence   bigint,
+    hash       text,
+    signature  text,
+    created_at timestamptz
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_checkpoints_sequence ON audit_checkpoints (sequence);
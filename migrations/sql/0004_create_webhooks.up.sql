@@ -0,0 +1,39 @@
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+    id         uuid PRIMARY KEY,
+    url        text,
+    secret     text,
+    events     text,
+    active     boolean DEFAULT true,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id           uuid PRIMARY KEY,
+    type         text,
+    payload      text,
+    created_at   timestamptz,
+    processed_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_type ON outbox_events (type);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_processed_at ON outbox_events (processed_at);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id              uuid PRIMARY KEY,
+    endpoint_id     uuid,
+    event_id        uuid,
+    event_type      text,
+    status          text,
+    attempts        bigint,
+    next_attempt_at timestamptz,
+    response_status bigint,
+    last_error      text,
+    created_at      timestamptz,
+    updated_at      timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_endpoint_id ON webhook_deliveries (endpoint_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_event_id ON webhook_deliveries (event_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_status ON webhook_deliveries (status);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_next_attempt_at ON webhook_deliveries (next_attempt_at);
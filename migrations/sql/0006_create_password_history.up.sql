@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS password_history (
+    id         bigserial PRIMARY KEY,
+    user_id    uuid REFERENCES users (id) ON DELETE CASCADE,
+    hash       text,
+    created_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_history_user_id ON password_history (user_id);
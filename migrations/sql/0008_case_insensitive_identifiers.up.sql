@@ -0,0 +1,16 @@
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM users GROUP BY lower(username) HAVING count(*) > 1) THEN
+        RAISE EXCEPTION 'usernames differing only in case exist; rename them before migrating (SELECT lower(username) FROM users GROUP BY 1 HAVING count(*) > 1)';
+    END IF;
+    IF EXISTS (SELECT 1 FROM users GROUP BY lower(email) HAVING count(*) > 1) THEN
+        RAISE EXCEPTION 'emails differing only in case exist; merge or change them before migrating (SELECT lower(email) FROM users GROUP BY 1 HAVING count(*) > 1)';
+    END IF;
+END $$;
+
+UPDATE users SET email = lower(email) WHERE email <> lower(email);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username_lower ON users (lower(username));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users (lower(email));
+DROP INDEX IF EXISTS idx_users_username;
+DROP INDEX IF EXISTS idx_users_email;
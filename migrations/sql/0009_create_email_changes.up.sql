@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS email_changes (
+    user_id            uuid PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    old_email          text NOT NULL,
+    new_email          text NOT NULL,
+    confirm_token_hash text NOT NULL,
+    cancel_token_hash  text NOT NULL,
+    confirmed_at       timestamptz,
+    expires_at         timestamptz NOT NULL,
+    created_at         timestamptz
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_email_changes_confirm_token_hash ON email_changes (confirm_token_hash);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_email_changes_cancel_token_hash ON email_changes (cancel_token_hash);
@@ -0,0 +1 @@
+ALTER TABLE audit_checkpoints ADD COLUMN IF NOT EXISTS key_id text;
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditEvent is a single entry in the tamper-evident audit log.
+// Each event stores the hash of the previous event, forming a chain.
+type AuditEvent struct {
+	Sequence  uint64     `gorm:"primaryKey;autoIncrement:false" json:"sequence"`
+	Action    string     `gorm:"index" json:"action"`
+	UserID    *uuid.UUID `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	Metadata  string     `json:"metadata,omitempty"`
+	PrevHash  string     `json:"prev_hash"`
+	Hash      string     `gorm:"uniqueIndex" json:"hash"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// AuditCheckpoint is a signed snapshot of the audit chain head.
+type AuditCheckpoint struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Sequence  uint64    `gorm:"uniqueIndex" json:"sequence"`
+	Hash      string    `json:"hash"`
+	Signature string    `json:"signature"`
+	KeyID     string    `json:"key_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
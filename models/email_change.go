@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmailChange is an email change waiting for the user to confirm the new address. Once
+// confirmed it is kept until it expires again so the previous address can still undo it.
+// Only hashes of the confirm and cancel tokens are stored.
+type EmailChange struct {
+	UserID           uuid.UUID  `gorm:"type:uuid;primary_key" json:"-"` // one change per user
+	OldEmail         string     `json:"-"`
+	NewEmail         string     `json:"new_email"`
+	ConfirmTokenHash string     `gorm:"uniqueIndex" json:"-"`
+	CancelTokenHash  string     `gorm:"uniqueIndex" json:"-"`
+	ConfirmedAt      *time.Time `json:"-"`
+	ExpiresAt        time.Time  `json:"expires_at"` // confirmation deadline, then end of the cancel window
+	CreatedAt        time.Time  `json:"-"`
+}
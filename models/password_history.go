@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PasswordHistory remembers a previous password hash of a user so it cannot be reused
+type PasswordHistory struct {
+	ID        uint64    `gorm:"primaryKey" json:"-"`
+	UserID    uuid.UUID `gorm:"type:uuid;index" json:"-"`
+	Hash      string    `json:"-"`
+	CreatedAt time.Time `json:"-"`
+}
+
+// TableName keeps the table name singular, like the concept it stores
+func (PasswordHistory) TableName() string {
+	return "password_history"
+}
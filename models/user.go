@@ -7,14 +7,25 @@ import (
 	"gorm.io/gorm"
 )
 
+// User roles
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
-	ID       uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	Username string    `gorm:"uniqueIndex" json:"username"`
-	Name     string    `json:"name"`
-	Email    string    `gorm:"uniqueIndex" json:"email"`
-	Password string    `json:"-"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID                 uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	Username           string    `gorm:"uniqueIndex:idx_users_username_lower,expression:lower(username)" json:"username"` // unique in any case, as in migration 0008
+	Name               string    `json:"name"`
+	Email              string    `gorm:"uniqueIndex:idx_users_email_lower,expression:lower(email)" json:"email"`
+	Password           string    `json:"-"`
+	Role               string    `gorm:"default:user" json:"role"`
+	Disabled           bool      `gorm:"not null;default:false" json:"disabled"`
+	TokenVersion       int       `gorm:"not null;default:0" json:"-"`                                   // bumped to revoke every issued token
+	PasswordChangedAt  time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"password_changed_at"` // starts the password's maximum age
+	MustChangePassword bool      `gorm:"not null;default:false" json:"must_change_password"`            // forces a change at the next login
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
 }
 
 // BeforeCreate will set a UUID rather than numeric ID
@@ -23,4 +34,4 @@ func (user *User) BeforeCreate(tx *gorm.DB) error {
 		user.ID = uuid.New()
 	}
 	return nil
-}
\ No newline at end of file
+}
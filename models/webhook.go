@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Webhook delivery states
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryDead      = "dead"
+)
+
+// WebhookEndpoint is an admin-configured receiver of lifecycle events
+type WebhookEndpoint struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"-"`
+	Events    string    `json:"events"` // comma-separated event types, "*" for all
+	Active    bool      `gorm:"default:true" json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// OutboxEvent is written in the same transaction as the change it describes
+type OutboxEvent struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	Type        string     `gorm:"index" json:"type"`
+	Payload     string     `json:"payload"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ProcessedAt *time.Time `gorm:"index" json:"processed_at,omitempty"`
+}
+
+// WebhookDelivery tracks the delivery of one outbox event to one endpoint
+type WebhookDelivery struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	EndpointID     uuid.UUID `gorm:"type:uuid;index" json:"endpoint_id"`
+	EventID        uuid.UUID `gorm:"type:uuid;index" json:"event_id"`
+	EventType      string    `json:"event_type"`
+	Status         string    `gorm:"index" json:"status"`
+	Attempts       int       `json:"attempts"`
+	NextAttemptAt  time.Time `gorm:"index" json:"next_attempt_at"`
+	ResponseStatus int       `json:"response_status,omitempty"`
+	LastError      string    `json:"last_error,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// BeforeCreate will set a UUID rather than numeric ID
+func (endpoint *WebhookEndpoint) BeforeCreate(tx *gorm.DB) error {
+	if endpoint.ID == uuid.Nil {
+		endpoint.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeCreate will set a UUID rather than numeric ID
+func (event *OutboxEvent) BeforeCreate(tx *gorm.DB) error {
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeCreate will set a UUID rather than numeric ID
+func (delivery *WebhookDelivery) BeforeCreate(tx *gorm.DB) error {
+	if delivery.ID == uuid.Nil {
+		delivery.ID = uuid.New()
+	}
+	return nil
+}
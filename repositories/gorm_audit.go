@@ -0,0 +1,57 @@
+package repositories
+
+import (
+	"azyqs-auth-systems/models"
+
+	"gorm.io/gorm"
+)
+
+// auditLockKey is the advisory lock key serializing appends to the chain
+const auditLockKey = 726_100_026
+
+type gormAuditRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormAuditRepository) LockChain() error {
+	if r.db.Dialector.Name() != "postgres" {
+		return nil
+	}
+	return r.db.Exec("SELECT pg_advisory_xact_lock(?)", auditLockKey).Error
+}
+
+func (r *gormAuditRepository) LastEvent() (*models.AuditEvent, error) {
+	var event models.AuditEvent
+	if err := r.db.Order("sequence DESC").First(&event).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &event, nil
+}
+
+func (r *gormAuditRepository) CreateEvent(event *models.AuditEvent) error {
+	return translateError(r.db.Create(event).Error)
+}
+
+func (r *gormAuditRepository) CreateCheckpoint(checkpoint *models.AuditCheckpoint) error {
+	return translateError(r.db.Create(checkpoint).Error)
+}
+
+func (r *gormAuditRepository) ListEvents(afterSequence uint64, limit int) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	err := r.db.Where("sequence > ?", afterSequence).Order("sequence ASC").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+func (r *gormAuditRepository) FindEvent(sequence uint64) (*models.AuditEvent, error) {
+	var event models.AuditEvent
+	if err := r.db.Where("sequence = ?", sequence).First(&event).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &event, nil
+}
+
+func (r *gormAuditRepository) ListCheckpoints() ([]models.AuditCheckpoint, error) {
+	var checkpoints []models.AuditCheckpoint
+	err := r.db.Order("sequence ASC").Find(&checkpoints).Error
+	return checkpoints, err
+}
@@ -0,0 +1,172 @@
+package repositories
+
+import (
+	serviceErrors "azyqs-auth-systems/errors"
+	"azyqs-auth-systems/models"
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// GormStore implements Store on top of a GORM connection
+type GormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore returns a Store backed by db
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+func (s *GormStore) Users() UserRepository       { return &gormUserRepository{db: s.db} }
+func (s *GormStore) Audit() AuditRepository      { return &gormAuditRepository{db: s.db} }
+func (s *GormStore) Webhooks() WebhookRepository { return &gormWebhookRepository{db: s.db} }
+
+// WithContext returns a Store whose queries carry ctx
+func (s *GormStore) WithContext(ctx context.Context) Store {
+	return &GormStore{db: s.db.WithContext(ctx)}
+}
+
+// Transaction runs fn inside a database transaction
+func (s *GormStore) Transaction(fn func(tx Store) error) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&GormStore{db: tx})
+	})
+}
+
+// translateError maps driver errors onto the errors package
+func translateError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return serviceErrors.ErrRecordNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey),
+		strings.Contains(err.Error(), "duplicate key value violates unique constraint"),
+		strings.Contains(err.Error(), "UNIQUE constraint failed"):
+		return serviceErrors.ErrDuplicateRecord
+	}
+	return err
+}
+
+type gormUserRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormUserRepository) FindByID(id uuid.UUID) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("id = ?", id).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) FindByUsername(username string) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("lower(username) = lower(?)", username).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) FindByEmail(email string) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("lower(email) = lower(?)", email).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) ExistsByUsernameOrEmail(username, email string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.User{}).Where("lower(username) = lower(?) OR lower(email) = lower(?)", username, email).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormUserRepository) UsernameTaken(username string, excludeID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.User{}).Where("lower(username) = lower(?) AND id != ?", username, excludeID).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormUserRepository) EmailTaken(email string, excludeID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.User{}).Where("lower(email) = lower(?) AND id != ?", email, excludeID).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormUserRepository) Create(user *models.User) error {
+	return translateError(r.db.Create(user).Error)
+}
+
+func (r *gormUserRepository) Update(user *models.User) error {
+	return translateError(r.db.Save(user).Error)
+}
+
+func (r *gormUserRepository) Delete(user *models.User) error {
+	if err := r.PrunePasswordHistory(user.ID, 0); err != nil {
+		return err
+	}
+	if err := r.DeleteEmailChange(user.ID); err != nil {
+		return err
+	}
+	return translateError(r.db.Delete(user).Error)
+}
+
+func (r *gormUserRepository) AddPasswordHistory(entry *models.PasswordHistory) error {
+	return translateError(r.db.Create(entry).Error)
+}
+
+func (r *gormUserRepository) ListPasswordHistory(userID uuid.UUID, limit int) ([]models.PasswordHistory, error) {
+	var entries []models.PasswordHistory
+	err := r.db.Where("user_id = ?", userID).Order("id DESC").Limit(limit).Find(&entries).Error
+	return entries, translateError(err)
+}
+
+func (r *gormUserRepository) PrunePasswordHistory(userID uuid.UUID, keep int) error {
+	query := r.db.Where("user_id = ?", userID)
+	if keep > 0 {
+		newest := r.db.Model(&models.PasswordHistory{}).Select("id").
+			Where("user_id = ?", userID).Order("id DESC").Limit(keep)
+		query = query.Where("id NOT IN (?)", newest)
+	}
+	return translateError(query.Delete(&models.PasswordHistory{}).Error)
+}
+
+func (r *gormUserRepository) SaveEmailChange(change *models.EmailChange) error {
+	if err := r.DeleteEmailChange(change.UserID); err != nil {
+		return err
+	}
+	return translateError(r.db.Create(change).Error)
+}
+
+func (r *gormUserRepository) FindEmailChange(userID uuid.UUID) (*models.EmailChange, error) {
+	var change models.EmailChange
+	if err := r.db.Where("user_id = ?", userID).First(&change).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &change, nil
+}
+
+func (r *gormUserRepository) FindEmailChangeByConfirmToken(tokenHash string) (*models.EmailChange, error) {
+	var change models.EmailChange
+	if err := r.db.Where("confirm_token_hash = ?", tokenHash).First(&change).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &change, nil
+}
+
+func (r *gormUserRepository) FindEmailChangeByCancelToken(tokenHash string) (*models.EmailChange, error) {
+	var change models.EmailChange
+	if err := r.db.Where("cancel_token_hash = ?", tokenHash).First(&change).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &change, nil
+}
+
+func (r *gormUserRepository) DeleteEmailChange(userID uuid.UUID) error {
+	return translateError(r.db.Where("user_id = ?", userID).Delete(&models.EmailChange{}).Error)
+}
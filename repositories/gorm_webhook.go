@@ -0,0 +1,147 @@
+package repositories
+
+import (
+	serviceErrors "azyqs-auth-systems/errors"
+	"azyqs-auth-systems/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormWebhookRepository struct {
+	db *gorm.DB
+}
+
+// skipLocked locks the selected rows and skips those held by other transactions
+func (r *gormWebhookRepository) skipLocked() *gorm.DB {
+	if r.db.Dialector.Name() != "postgres" {
+		return r.db
+	}
+	return r.db.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+}
+
+func (r *gormWebhookRepository) CreateEndpoint(endpoint *models.WebhookEndpoint) error {
+	return translateError(r.db.Create(endpoint).Error)
+}
+
+func (r *gormWebhookRepository) ListEndpoints() ([]models.WebhookEndpoint, error) {
+	var endpoints []models.WebhookEndpoint
+	err := r.db.Order("created_at ASC").Find(&endpoints).Error
+	return endpoints, err
+}
+
+func (r *gormWebhookRepository) ListActiveEndpoints() ([]models.WebhookEndpoint, error) {
+	var endpoints []models.WebhookEndpoint
+	err := r.db.Where("active = ?", true).Find(&endpoints).Error
+	return endpoints, err
+}
+
+func (r *gormWebhookRepository) FindEndpoint(id uuid.UUID) (*models.WebhookEndpoint, error) {
+	var endpoint models.WebhookEndpoint
+	if err := r.db.Where("id = ?", id).First(&endpoint).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &endpoint, nil
+}
+
+func (r *gormWebhookRepository) DeleteEndpoint(id uuid.UUID) error {
+	result := r.db.Delete(&models.WebhookEndpoint{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return serviceErrors.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *gormWebhookRepository) CreateOutboxEvent(event *models.OutboxEvent) error {
+	return translateError(r.db.Create(event).Error)
+}
+
+func (r *gormWebhookRepository) ClaimOutboxEvents(limit int) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+	err := r.skipLocked().
+		Where("processed_at IS NULL").
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&events).Error
+	return events, err
+}
+
+func (r *gormWebhookRepository) MarkOutboxEventProcessed(id uuid.UUID, at time.Time) error {
+	return r.db.Model(&models.OutboxEvent{}).Where("id = ?", id).Update("processed_at", at).Error
+}
+
+func (r *gormWebhookRepository) FindOutboxEvent(id uuid.UUID) (*models.OutboxEvent, error) {
+	var event models.OutboxEvent
+	if err := r.db.Where("id = ?", id).First(&event).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &event, nil
+}
+
+func (r *gormWebhookRepository) CreateDelivery(delivery *models.WebhookDelivery) error {
+	return translateError(r.db.Create(delivery).Error)
+}
+
+func (r *gormWebhookRepository) ClaimDueDeliveries(now time.Time, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		claim := &gormWebhookRepository{db: tx}
+		if err := claim.skipLocked().
+			Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, now).
+			Order("next_attempt_at ASC").
+			Limit(limit).
+			Find(&deliveries).Error; err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		// Lease the claimed rows so other instances skip them while we deliver
+		ids := make([]uuid.UUID, len(deliveries))
+		for i, delivery := range deliveries {
+			ids[i] = delivery.ID
+		}
+		return tx.Model(&models.WebhookDelivery{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(lease)).Error
+	})
+	return deliveries, err
+}
+
+func (r *gormWebhookRepository) SaveDelivery(delivery *models.WebhookDelivery) error {
+	return translateError(r.db.Save(delivery).Error)
+}
+
+func (r *gormWebhookRepository) ListDeliveries(endpointID uuid.UUID, status string, limit int) ([]models.WebhookDelivery, error) {
+	query := r.db.Where("endpoint_id = ?", endpointID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var deliveries []models.WebhookDelivery
+	err := query.Order("created_at DESC").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+func (r *gormWebhookRepository) RequeueDeadDelivery(id uuid.UUID, at time.Time) error {
+	result := r.db.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ?", id, models.DeliveryDead).
+		Updates(map[string]interface{}{
+			"status":          models.DeliveryPending,
+			"attempts":        0,
+			"next_attempt_at": at,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return serviceErrors.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	serviceErrors "azyqs-auth-systems/errors"
+	"azyqs-auth-systems/models"
+	"time"
+)
+
+type memoryAuditRepository struct {
+	s *MemoryStore
+}
+
+// LockChain is a no-op: transactions already hold the store lock
+func (r *memoryAuditRepository) LockChain() error {
+	return nil
+}
+
+func (r *memoryAuditRepository) LastEvent() (*models.AuditEvent, error) {
+	defer r.s.lock()()
+	events := r.s.state.auditEvents
+	if len(events) == 0 {
+		return nil, serviceErrors.ErrRecordNotFound
+	}
+	event := events[len(events)-1]
+	return &event, nil
+}
+
+func (r *memoryAuditRepository) CreateEvent(event *models.AuditEvent) error {
+	defer r.s.lock()()
+	for _, existing := range r.s.state.auditEvents {
+		if existing.Sequence == event.Sequence || existing.Hash == event.Hash {
+			return serviceErrors.ErrDuplicateRecord
+		}
+	}
+	r.s.state.auditEvents = append(r.s.state.auditEvents, *event)
+	return nil
+}
+
+func (r *memoryAuditRepository) CreateCheckpoint(checkpoint *models.AuditCheckpoint) error {
+	defer r.s.lock()()
+	checkpoint.ID = uint(len(r.s.state.checkpoints) + 1)
+	if checkpoint.CreatedAt.IsZero() {
+		checkpoint.CreatedAt = time.Now()
+	}
+	r.s.state.checkpoints = append(r.s.state.checkpoints, *checkpoint)
+	return nil
+}
+
+func (r *memoryAuditRepository) ListEvents(afterSequence uint64, limit int) ([]models.AuditEvent, error) {
+	defer r.s.lock()()
+	var events []models.AuditEvent
+	for _, event := range r.s.state.auditEvents {
+		if event.Sequence > afterSequence {
+			events = append(events, event)
+			if len(events) == limit {
+				break
+			}
+		}
+	}
+	return events, nil
+}
+
+func (r *memoryAuditRepository) FindEvent(sequence uint64) (*models.AuditEvent, error) {
+	defer r.s.lock()()
+	for _, event := range r.s.state.auditEvents {
+		if event.Sequence == sequence {
+			return &event, nil
+		}
+	}
+	return nil, serviceErrors.ErrRecordNotFound
+}
+
+func (r *memoryAuditRepository) ListCheckpoints() ([]models.AuditCheckpoint, error) {
+	defer r.s.lock()()
+	return append([]models.AuditCheckpoint(nil), r.s.state.checkpoints...), nil
+}
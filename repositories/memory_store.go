@@ -0,0 +1,305 @@
+package repositories
+
+import (
+	serviceErrors "azyqs-auth-systems/errors"
+	"azyqs-auth-systems/models"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore implements Store in process memory.
+// It is meant for tests and local experiments; all data is lost on exit.
+type MemoryStore struct {
+	mu    *sync.Mutex
+	state *memoryState
+	inTx  bool
+}
+
+type memoryState struct {
+	users        map[uuid.UUID]models.User
+	passwords    map[uuid.UUID][]models.PasswordHistory // oldest first
+	passwordSeq  uint64
+	emailChanges map[uuid.UUID]models.EmailChange
+	auditEvents  []models.AuditEvent
+	checkpoints  []models.AuditCheckpoint
+	endpoints    map[uuid.UUID]models.WebhookEndpoint
+	outbox       []models.OutboxEvent
+	deliveries   map[uuid.UUID]models.WebhookDelivery
+}
+
+// NewMemoryStore returns an empty in-memory Store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		mu: &sync.Mutex{},
+		state: &memoryState{
+			users:        map[uuid.UUID]models.User{},
+			passwords:    map[uuid.UUID][]models.PasswordHistory{},
+			emailChanges: map[uuid.UUID]models.EmailChange{},
+			endpoints:    map[uuid.UUID]models.WebhookEndpoint{},
+			deliveries:   map[uuid.UUID]models.WebhookDelivery{},
+		},
+	}
+}
+
+func (s *MemoryStore) Users() UserRepository       { return &memoryUserRepository{s: s} }
+func (s *MemoryStore) Audit() AuditRepository      { return &memoryAuditRepository{s: s} }
+func (s *MemoryStore) Webhooks() WebhookRepository { return &memoryWebhookRepository{s: s} }
+
+// WithContext returns the store itself; in-memory operations cannot be cancelled
+func (s *MemoryStore) WithContext(ctx context.Context) Store {
+	return s
+}
+
+// Transaction runs fn while holding the store lock and rolls back on error
+func (s *MemoryStore) Transaction(fn func(tx Store) error) error {
+	if s.inTx {
+		return fn(s)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := s.state.clone()
+	if err := fn(&MemoryStore{mu: s.mu, state: s.state, inTx: true}); err != nil {
+		*s.state = *snapshot
+		return err
+	}
+	return nil
+}
+
+// lock acquires the store lock unless the caller already holds it through a transaction
+func (s *MemoryStore) lock() func() {
+	if s.inTx {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+func (st *memoryState) clone() *memoryState {
+	c := &memoryState{
+		users:        make(map[uuid.UUID]models.User, len(st.users)),
+		passwords:    make(map[uuid.UUID][]models.PasswordHistory, len(st.passwords)),
+		passwordSeq:  st.passwordSeq,
+		emailChanges: make(map[uuid.UUID]models.EmailChange, len(st.emailChanges)),
+		auditEvents:  append([]models.AuditEvent(nil), st.auditEvents...),
+		checkpoints:  append([]models.AuditCheckpoint(nil), st.checkpoints...),
+		endpoints:    make(map[uuid.UUID]models.WebhookEndpoint, len(st.endpoints)),
+		outbox:       append([]models.OutboxEvent(nil), st.outbox...),
+		deliveries:   make(map[uuid.UUID]models.WebhookDelivery, len(st.deliveries)),
+	}
+	for k, v := range st.users {
+		c.users[k] = v
+	}
+	for k, v := range st.passwords {
+		c.passwords[k] = append([]models.PasswordHistory(nil), v...)
+	}
+	for k, v := range st.emailChanges {
+		c.emailChanges[k] = v
+	}
+	for k, v := range st.endpoints {
+		c.endpoints[k] = v
+	}
+	for k, v := range st.deliveries {
+		c.deliveries[k] = v
+	}
+	return c
+}
+
+type memoryUserRepository struct {
+	s *MemoryStore
+}
+
+func (r *memoryUserRepository) FindByID(id uuid.UUID) (*models.User, error) {
+	defer r.s.lock()()
+	user, ok := r.s.state.users[id]
+	if !ok {
+		return nil, serviceErrors.ErrRecordNotFound
+	}
+	return &user, nil
+}
+
+func (r *memoryUserRepository) FindByUsername(username string) (*models.User, error) {
+	defer r.s.lock()()
+	for _, user := range r.s.state.users {
+		if strings.EqualFold(user.Username, username) {
+			return &user, nil
+		}
+	}
+	return nil, serviceErrors.ErrRecordNotFound
+}
+
+func (r *memoryUserRepository) FindByEmail(email string) (*models.User, error) {
+	defer r.s.lock()()
+	for _, user := range r.s.state.users {
+		if strings.EqualFold(user.Email, email) {
+			return &user, nil
+		}
+	}
+	return nil, serviceErrors.ErrRecordNotFound
+}
+
+func (r *memoryUserRepository) ExistsByUsernameOrEmail(username, email string) (bool, error) {
+	defer r.s.lock()()
+	for _, user := range r.s.state.users {
+		if strings.EqualFold(user.Username, username) || strings.EqualFold(user.Email, email) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryUserRepository) UsernameTaken(username string, excludeID uuid.UUID) (bool, error) {
+	defer r.s.lock()()
+	for _, user := range r.s.state.users {
+		if strings.EqualFold(user.Username, username) && user.ID != excludeID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryUserRepository) EmailTaken(email string, excludeID uuid.UUID) (bool, error) {
+	defer r.s.lock()()
+	for _, user := range r.s.state.users {
+		if strings.EqualFold(user.Email, email) && user.ID != excludeID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryUserRepository) Create(user *models.User) error {
+	defer r.s.lock()()
+	if user.ID == uuid.Nil {
+		user.ID = uuid.New()
+	}
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+	if err := r.checkUnique(user); err != nil {
+		return err
+	}
+	now := time.Now()
+	user.CreatedAt, user.UpdatedAt = now, now
+	if user.PasswordChangedAt.IsZero() {
+		user.PasswordChangedAt = now
+	}
+	r.s.state.users[user.ID] = *user
+	return nil
+}
+
+func (r *memoryUserRepository) Update(user *models.User) error {
+	defer r.s.lock()()
+	if err := r.checkUnique(user); err != nil {
+		return err
+	}
+	user.UpdatedAt = time.Now()
+	r.s.state.users[user.ID] = *user
+	return nil
+}
+
+func (r *memoryUserRepository) Delete(user *models.User) error {
+	defer r.s.lock()()
+	delete(r.s.state.users, user.ID)
+	delete(r.s.state.passwords, user.ID)
+	delete(r.s.state.emailChanges, user.ID)
+	return nil
+}
+
+func (r *memoryUserRepository) AddPasswordHistory(entry *models.PasswordHistory) error {
+	defer r.s.lock()()
+	r.s.state.passwordSeq++
+	entry.ID = r.s.state.passwordSeq
+	entry.CreatedAt = time.Now()
+	r.s.state.passwords[entry.UserID] = append(r.s.state.passwords[entry.UserID], *entry)
+	return nil
+}
+
+func (r *memoryUserRepository) ListPasswordHistory(userID uuid.UUID, limit int) ([]models.PasswordHistory, error) {
+	defer r.s.lock()()
+	history := r.s.state.passwords[userID]
+	var entries []models.PasswordHistory
+	for i := len(history) - 1; i >= 0 && len(entries) < limit; i-- {
+		entries = append(entries, history[i])
+	}
+	return entries, nil
+}
+
+func (r *memoryUserRepository) PrunePasswordHistory(userID uuid.UUID, keep int) error {
+	defer r.s.lock()()
+	history := r.s.state.passwords[userID]
+	if keep <= 0 {
+		delete(r.s.state.passwords, userID)
+		return nil
+	}
+	if len(history) > keep {
+		r.s.state.passwords[userID] = append([]models.PasswordHistory(nil), history[len(history)-keep:]...)
+	}
+	return nil
+}
+
+func (r *memoryUserRepository) SaveEmailChange(change *models.EmailChange) error {
+	defer r.s.lock()()
+	for _, existing := range r.s.state.emailChanges {
+		if existing.UserID != change.UserID &&
+			(existing.ConfirmTokenHash == change.ConfirmTokenHash || existing.CancelTokenHash == change.CancelTokenHash) {
+			return serviceErrors.ErrDuplicateRecord
+		}
+	}
+	change.CreatedAt = time.Now()
+	r.s.state.emailChanges[change.UserID] = *change
+	return nil
+}
+
+func (r *memoryUserRepository) FindEmailChange(userID uuid.UUID) (*models.EmailChange, error) {
+	defer r.s.lock()()
+	change, ok := r.s.state.emailChanges[userID]
+	if !ok {
+		return nil, serviceErrors.ErrRecordNotFound
+	}
+	return &change, nil
+}
+
+func (r *memoryUserRepository) FindEmailChangeByConfirmToken(tokenHash string) (*models.EmailChange, error) {
+	defer r.s.lock()()
+	for _, change := range r.s.state.emailChanges {
+		if change.ConfirmTokenHash == tokenHash {
+			return &change, nil
+		}
+	}
+	return nil, serviceErrors.ErrRecordNotFound
+}
+
+func (r *memoryUserRepository) FindEmailChangeByCancelToken(tokenHash string) (*models.EmailChange, error) {
+	defer r.s.lock()()
+	for _, change := range r.s.state.emailChanges {
+		if change.CancelTokenHash == tokenHash {
+			return &change, nil
+		}
+	}
+	return nil, serviceErrors.ErrRecordNotFound
+}
+
+func (r *memoryUserRepository) DeleteEmailChange(userID uuid.UUID) error {
+	defer r.s.lock()()
+	delete(r.s.state.emailChanges, userID)
+	return nil
+}
+
+// checkUnique mirrors the case-insensitive unique indexes on username and email
+func (r *memoryUserRepository) checkUnique(user *models.User) error {
+	for _, existing := range r.s.state.users {
+		if existing.ID == user.ID {
+			continue
+		}
+		if strings.EqualFold(existing.Username, user.Username) || strings.EqualFold(existing.Email, user.Email) {
+			return serviceErrors.ErrDuplicateRecord
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package repositories
+
+import (
+	serviceErrors "azyqs-auth-systems/errors"
+	"azyqs-auth-systems/models"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memoryWebhookRepository struct {
+	s *MemoryStore
+}
+
+func (r *memoryWebhookRepository) CreateEndpoint(endpoint *models.WebhookEndpoint) error {
+	defer r.s.lock()()
+	if endpoint.ID == uuid.Nil {
+		endpoint.ID = uuid.New()
+	}
+	now := time.Now()
+	endpoint.CreatedAt, endpoint.UpdatedAt = now, now
+	r.s.state.endpoints[endpoint.ID] = *endpoint
+	return nil
+}
+
+func (r *memoryWebhookRepository) ListEndpoints() ([]models.WebhookEndpoint, error) {
+	defer r.s.lock()()
+	endpoints := make([]models.WebhookEndpoint, 0, len(r.s.state.endpoints))
+	for _, endpoint := range r.s.state.endpoints {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].CreatedAt.Before(endpoints[j].CreatedAt)
+	})
+	return endpoints, nil
+}
+
+func (r *memoryWebhookRepository) ListActiveEndpoints() ([]models.WebhookEndpoint, error) {
+	endpoints, _ := r.ListEndpoints()
+	active := endpoints[:0]
+	for _, endpoint := range endpoints {
+		if endpoint.Active {
+			active = append(active, endpoint)
+		}
+	}
+	return active, nil
+}
+
+func (r *memoryWebhookRepository) FindEndpoint(id uuid.UUID) (*models.WebhookEndpoint, error) {
+	defer r.s.lock()()
+	endpoint, ok := r.s.state.endpoints[id]
+	if !ok {
+		return nil, serviceErrors.ErrRecordNotFound
+	}
+	return &endpoint, nil
+}
+
+func (r *memoryWebhookRepository) DeleteEndpoint(id uuid.UUID) error {
+	defer r.s.lock()()
+	if _, ok := r.s.state.endpoints[id]; !ok {
+		return serviceErrors.ErrRecordNotFound
+	}
+	delete(r.s.state.endpoints, id)
+	return nil
+}
+
+func (r *memoryWebhookRepository) CreateOutboxEvent(event *models.OutboxEvent) error {
+	defer r.s.lock()()
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+	event.CreatedAt = time.Now()
+	r.s.state.outbox = append(r.s.state.outbox, *event)
+	return nil
+}
+
+func (r *memoryWebhookRepository) ClaimOutboxEvents(limit int) ([]models.OutboxEvent, error) {
+	defer r.s.lock()()
+	var events []models.OutboxEvent
+	for _, event := range r.s.state.outbox {
+		if event.ProcessedAt == nil {
+			events = append(events, event)
+			if len(events) == limit {
+				break
+			}
+		}
+	}
+	return events, nil
+}
+
+func (r *memoryWebhookRepository) MarkOutboxEventProcessed(id uuid.UUID, at time.Time) error {
+	defer r.s.lock()()
+	for i := range r.s.state.outbox {
+		if r.s.state.outbox[i].ID == id {
+			processedAt := at
+			r.s.state.outbox[i].ProcessedAt = &processedAt
+			return nil
+		}
+	}
+	return serviceErrors.ErrRecordNotFound
+}
+
+func (r *memoryWebhookRepository) FindOutboxEvent(id uuid.UUID) (*models.OutboxEvent, error) {
+	defer r.s.lock()()
+	for _, event := range r.s.state.outbox {
+		if event.ID == id {
+			return &event, nil
+		}
+	}
+	return nil, serviceErrors.ErrRecordNotFound
+}
+
+func (r *memoryWebhookRepository) CreateDelivery(delivery *models.WebhookDelivery) error {
+	defer r.s.lock()()
+	if delivery.ID == uuid.Nil {
+		delivery.ID = uuid.New()
+	}
+	now := time.Now()
+	delivery.CreatedAt, delivery.UpdatedAt = now, now
+	r.s.state.deliveries[delivery.ID] = *delivery
+	return nil
+}
+
+func (r *memoryWebhookRepository) ClaimDueDeliveries(now time.Time, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	defer r.s.lock()()
+	var deliveries []models.WebhookDelivery
+	for id, delivery := range r.s.state.deliveries {
+		if delivery.Status != models.DeliveryPending || delivery.NextAttemptAt.After(now) {
+			continue
+		}
+		claimed := delivery
+		claimed.NextAttemptAt = now.Add(lease)
+		r.s.state.deliveries[id] = claimed
+		deliveries = append(deliveries, delivery)
+		if len(deliveries) == limit {
+			break
+		}
+	}
+	return deliveries, nil
+}
+
+func (r *memoryWebhookRepository) SaveDelivery(delivery *models.WebhookDelivery) error {
+	defer r.s.lock()()
+	delivery.UpdatedAt = time.Now()
+	r.s.state.deliveries[delivery.ID] = *delivery
+	return nil
+}
+
+func (r *memoryWebhookRepository) ListDeliveries(endpointID uuid.UUID, status string, limit int) ([]models.WebhookDelivery, error) {
+	defer r.s.lock()()
+	var deliveries []models.WebhookDelivery
+	for _, delivery := range r.s.state.deliveries {
+		if delivery.EndpointID == endpointID && (status == "" || delivery.Status == status) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+	})
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+func (r *memoryWebhookRepository) RequeueDeadDelivery(id uuid.UUID, at time.Time) error {
+	defer r.s.lock()()
+	delivery, ok := r.s.state.deliveries[id]
+	if !ok || delivery.Status != models.DeliveryDead {
+		return serviceErrors.ErrRecordNotFound
+	}
+	delivery.Status = models.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = at
+	r.s.state.deliveries[id] = delivery
+	return nil
+}
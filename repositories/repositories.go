@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"azyqs-auth-systems/models"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Store gives access to every repository and runs work in a transaction.
+// Repositories obtained from the Store passed to fn share its transaction.
+type Store interface {
+	Users() UserRepository
+	Audit() AuditRepository
+	Webhooks() WebhookRepository
+	Transaction(fn func(tx Store) error) error
+	// WithContext returns a Store whose queries run under ctx, for cancellation and tracing
+	WithContext(ctx context.Context) Store
+}
+
+// UserRepository persists users, their password history and pending email changes.
+// Usernames and emails are matched case-insensitively.
+// Lookups return errors.ErrRecordNotFound when nothing matches and
+// writes return errors.ErrDuplicateRecord on a unique violation.
+// Deleting a user also deletes their password history and pending email change.
+type UserRepository interface {
+	FindByID(id uuid.UUID) (*models.User, error)
+	FindByUsername(username string) (*models.User, error)
+	FindByEmail(email string) (*models.User, error)
+	ExistsByUsernameOrEmail(username, email string) (bool, error)
+	UsernameTaken(username string, excludeID uuid.UUID) (bool, error)
+	EmailTaken(email string, excludeID uuid.UUID) (bool, error)
+	Create(user *models.User) error
+	Update(user *models.User) error
+	Delete(user *models.User) error
+
+	AddPasswordHistory(entry *models.PasswordHistory) error
+	// ListPasswordHistory returns up to limit entries of a user, newest first
+	ListPasswordHistory(userID uuid.UUID, limit int) ([]models.PasswordHistory, error)
+	// PrunePasswordHistory deletes all but the newest keep entries of a user
+	PrunePasswordHistory(userID uuid.UUID, keep int) error
+
+	// SaveEmailChange replaces the email change of a user
+	SaveEmailChange(change *models.EmailChange) error
+	FindEmailChange(userID uuid.UUID) (*models.EmailChange, error)
+	FindEmailChangeByConfirmToken(tokenHash string) (*models.EmailChange, error)
+	FindEmailChangeByCancelToken(tokenHash string) (*models.EmailChange, error)
+	DeleteEmailChange(userID uuid.UUID) error
+}
+
+// AuditRepository persists the audit hash chain.
+// LockChain must be called inside a transaction before reading the chain head.
+type AuditRepository interface {
+	LockChain() error
+	LastEvent() (*models.AuditEvent, error)
+	CreateEvent(event *models.AuditEvent) error
+	CreateCheckpoint(checkpoint *models.AuditCheckpoint) error
+	ListEvents(afterSequence uint64, limit int) ([]models.AuditEvent, error)
+	FindEvent(sequence uint64) (*models.AuditEvent, error)
+	ListCheckpoints() ([]models.AuditCheckpoint, error)
+}
+
+// WebhookRepository persists webhook endpoints, the outbox and the delivery log.
+// Claim methods skip rows locked by other instances.
+type WebhookRepository interface {
+	CreateEndpoint(endpoint *models.WebhookEndpoint) error
+	ListEndpoints() ([]models.WebhookEndpoint, error)
+	ListActiveEndpoints() ([]models.WebhookEndpoint, error)
+	FindEndpoint(id uuid.UUID) (*models.WebhookEndpoint, error)
+	DeleteEndpoint(id uuid.UUID) error
+
+	CreateOutboxEvent(event *models.OutboxEvent) error
+	ClaimOutboxEvents(limit int) ([]models.OutboxEvent, error)
+	MarkOutboxEventProcessed(id uuid.UUID, at time.Time) error
+	FindOutboxEvent(id uuid.UUID) (*models.OutboxEvent, error)
+
+	CreateDelivery(delivery *models.WebhookDelivery) error
+	ClaimDueDeliveries(now time.Time, limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	SaveDelivery(delivery *models.WebhookDelivery) error
+	ListDeliveries(endpointID uuid.UUID, status string, limit int) ([]models.WebhookDelivery, error)
+	RequeueDeadDelivery(id uuid.UUID, at time.Time) error
+}
@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"azyqs-auth-systems/controllers"
+	"azyqs-auth-systems/middlewares"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// RegisterAdminRoutes defines routes restricted to administrators
+func RegisterAdminRoutes(router *mux.Router, h *controllers.Handler) {
+	admin := router.PathPrefix("/admin").Subrouter()
+	admin.Use(middlewares.JwtAuthentication(h.Users))
+	admin.Use(middlewares.RequireAdmin(h.Users))
+
+	admin.HandleFunc("/webhooks", h.CreateWebhook).Methods("POST")
+	admin.HandleFunc("/webhooks", h.ListWebhooks).Methods("GET")
+	admin.HandleFunc("/webhooks/{id}", h.DeleteWebhook).Methods("DELETE")
+	admin.HandleFunc("/webhooks/{id}/deliveries", h.ListWebhookDeliveries).Methods("GET")
+	admin.HandleFunc("/webhooks/deliveries/{id}/retry", h.RetryWebhookDelivery).Methods("POST")
+	admin.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowedHandler)
+}
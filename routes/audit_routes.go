@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"azyqs-auth-systems/controllers"
+	"azyqs-auth-systems/middlewares"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// RegisterAuditRoutes defines routes for audit log operations, restricted to administrators
+func RegisterAuditRoutes(router *mux.Router, h *controllers.Handler) {
+	protected := router.PathPrefix("/audit").Subrouter()
+	protected.Use(middlewares.JwtAuthentication(h.Users))
+	protected.Use(middlewares.RequireAdmin(h.Users))
+
+	protected.HandleFunc("/verify", h.VerifyAudit).Methods("GET")
+	protected.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowedHandler)
+}
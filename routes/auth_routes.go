@@ -2,15 +2,27 @@ package routes
 
 import (
 	"azyqs-auth-systems/controllers"
+	"azyqs-auth-systems/middlewares"
 	"net/http"
 
 	"github.com/gorilla/mux"
 )
 
 // RegisterAuthRoutes defines routes for authentication
-func RegisterAuthRoutes(router *mux.Router) {
+func RegisterAuthRoutes(router *mux.Router, h *controllers.Handler) {
 	authRouter := router.PathPrefix("/auth").Subrouter()
-	authRouter.HandleFunc("/register", controllers.Register).Methods("POST")
-	authRouter.HandleFunc("/login", controllers.Login).Methods("POST")
+	// mux forgets a method mismatch when a later route misses the path, so GET routes go first
+	authRouter.HandleFunc("/password-policy", h.PasswordPolicy).Methods("GET")
+	// Opening an emailed link only returns its token; posting the token makes the change
+	authRouter.HandleFunc("/email-change/confirm", h.EmailChangeLink).Methods("GET")
+	authRouter.HandleFunc("/email-change/cancel", h.EmailChangeLink).Methods("GET")
+	// Re-entering the password needs a full session; it refreshes the authentication time
+	authenticated := middlewares.JwtAuthentication(h.Users)
+	authRouter.Handle("/reauthenticate", authenticated(http.HandlerFunc(h.Reauthenticate))).Methods("POST")
+	// Email change links carry no session; the token alone identifies the change
+	authRouter.HandleFunc("/email-change/confirm", h.ConfirmEmailChange).Methods("POST")
+	authRouter.HandleFunc("/email-change/cancel", h.CancelEmailChange).Methods("POST")
+	authRouter.HandleFunc("/register", h.Register).Methods("POST")
+	authRouter.HandleFunc("/login", h.Login).Methods("POST")
 	authRouter.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowedHandler)
 }
@@ -0,0 +1,326 @@
+package routes_test
+
+import (
+	"azyqs-auth-systems/controllers"
+	serviceErrors "azyqs-auth-systems/errors"
+	"azyqs-auth-systems/mailer"
+	"azyqs-auth-systems/models"
+	"azyqs-auth-systems/repositories"
+	"azyqs-auth-systems/routes"
+	"azyqs-auth-systems/services"
+	"azyqs-auth-systems/utils"
+	"azyqs-auth-systems/validators"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const testPassword = "Passw0rd!"
+
+func TestMain(m *testing.M) {
+	// Keep the suite fast and quiet
+	utils.Hasher = utils.BcryptHasher{Cost: bcrypt.MinCost}
+	validators.MaxPasswordBytes = utils.BcryptMaxPasswordBytes
+	utils.Keys = utils.NewKeyring([]byte("test_secret_key_for_the_routes_suite"))
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// backends lists every Store implementation the HTTP suite runs against
+var backends = map[string]func(t *testing.T) repositories.Store{
+	"memory": func(t *testing.T) repositories.Store { return repositories.NewMemoryStore() },
+	"sqlite": newSQLiteStore,
+}
+
+func newSQLiteStore(t *testing.T) repositories.Store {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	// The schema comes from the models; migrations.TestModelsMatchEmbeddedMigrations keeps
+	// them in line with the SQL migrations production runs
+	err = db.AutoMigrate(
+		&models.User{},
+		&models.PasswordHistory{},
+		&models.EmailChange{},
+		&models.AuditEvent{},
+		&models.AuditCheckpoint{},
+		&models.WebhookEndpoint{},
+		&models.OutboxEvent{},
+		&models.WebhookDelivery{},
+	)
+	if err != nil {
+		t.Fatalf("failed to migrate sqlite: %v", err)
+	}
+	return repositories.NewGormStore(db)
+}
+
+// forEachBackend runs fn once per Store implementation
+func forEachBackend(t *testing.T, fn func(t *testing.T, s *testServer)) {
+	for name, newStore := range backends {
+		t.Run(name, func(t *testing.T) {
+			fn(t, newTestServer(t, newStore(t)))
+		})
+	}
+}
+
+type testServer struct {
+	t       *testing.T
+	store   repositories.Store
+	handler *controllers.Handler
+	router  *mux.Router
+	mail    *testMailer
+}
+
+// testMailer records sent messages instead of delivering them
+type testMailer struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+}
+
+func (m *testMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func (m *testMailer) Ping(ctx context.Context) error {
+	return nil
+}
+
+type apiResponse struct {
+	Code             int
+	Status           string              `json:"status"`
+	Message          string              `json:"message"`
+	LocalizedMessage string              `json:"localized_message"`
+	Data             json.RawMessage     `json:"data"`
+	Errors           map[string][]string `json:"errors"`
+}
+
+func newTestServer(t *testing.T, store repositories.Store) *testServer {
+	audit := services.NewAuditService(store, utils.Keys)
+	mail := &testMailer{}
+	handler := &controllers.Handler{
+		Auth:     services.NewAuthService(store, audit),
+		Users:    services.NewUserService(store, audit, mail),
+		Audit:    audit,
+		Webhooks: services.NewWebhookService(store),
+		Health:   services.NewHealthService(time.Second),
+	}
+	router := mux.NewRouter()
+	routes.RegisterRoutes(router, handler)
+	return &testServer{t: t, store: store, handler: handler, router: router, mail: mail}
+}
+
+// do sends a request through the router and decodes the standard response
+func (s *testServer) do(method, path, body, token string) apiResponse {
+	s.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+
+	resp := apiResponse{Code: rec.Code}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		s.t.Fatalf("%s %s: invalid JSON response %q: %v", method, path, rec.Body.String(), err)
+	}
+	return resp
+}
+
+// register creates a user with testPassword and fails the test on error
+func (s *testServer) register(username, email string) {
+	s.t.Helper()
+	body := `{"username":"` + username + `","name":"Test User","email":"` + email + `","password":"` + testPassword + `"}`
+	resp := s.do("POST", "/auth/register", body, "")
+	expect(s.t, resp, http.StatusOK, "registration_successful")
+}
+
+// login returns a token for username and fails the test on error
+func (s *testServer) login(username, password string) string {
+	s.t.Helper()
+	resp := s.do("POST", "/auth/login", `{"username":"`+username+`","password":"`+password+`"}`, "")
+	expect(s.t, resp, http.StatusOK, "login_successful")
+
+	var data struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(resp.Data, &data); err != nil || data.Token == "" {
+		s.t.Fatalf("login response has no token: %s", resp.Data)
+	}
+	return data.Token
+}
+
+// reauthenticate trades token for an elevated one by re-entering password and fails the
+// test on error
+func (s *testServer) reauthenticate(token, password string) string {
+	s.t.Helper()
+	resp := s.do("POST", "/auth/reauthenticate", `{"password":"`+password+`"}`, token)
+	expect(s.t, resp, http.StatusOK, "reauthenticated")
+
+	var data struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(resp.Data, &data); err != nil || data.Token == "" {
+		s.t.Fatalf("reauthentication response has no token: %s", resp.Data)
+	}
+	return data.Token
+}
+
+// loginToChangePassword returns the restricted token issued to a user who must change
+// their password and fails the test on any other outcome
+func (s *testServer) loginToChangePassword(username, password string) string {
+	s.t.Helper()
+	resp := s.do("POST", "/auth/login", `{"username":"`+username+`","password":"`+password+`"}`, "")
+	expect(s.t, resp, http.StatusOK, "password_change_required")
+
+	var data struct {
+		Token              string `json:"token"`
+		MustChangePassword bool   `json:"must_change_password"`
+	}
+	if err := json.Unmarshal(resp.Data, &data); err != nil || data.Token == "" || !data.MustChangePassword {
+		s.t.Fatalf("login response has no restricted token: %s", resp.Data)
+	}
+	return data.Token
+}
+
+// emailLink returns the newest email link for action ("confirm" or "cancel") sent to address
+// as a path the router serves, and fails the test if there is none or it leaves the API
+func (s *testServer) emailLink(address, action string) string {
+	s.t.Helper()
+	s.mail.mu.Lock()
+	defer s.mail.mu.Unlock()
+	for i := len(s.mail.sent) - 1; i >= 0; i-- {
+		msg := s.mail.sent[i]
+		if len(msg.To) != 1 || msg.To[0] != address {
+			continue
+		}
+		for _, field := range strings.Fields(msg.Body) {
+			if path, ok := strings.CutPrefix(field, services.LinkBaseURL); ok && strings.Contains(path, "/email-change/"+action+"?") {
+				return path
+			}
+		}
+	}
+	s.t.Fatalf("no %s link under %s was sent to %s", action, services.LinkBaseURL, address)
+	return ""
+}
+
+// linkToken returns the token carried by an email link
+func (s *testServer) linkToken(link string) string {
+	s.t.Helper()
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Query().Get("token") == "" {
+		s.t.Fatalf("link %q carries no token", link)
+	}
+	return parsed.Query().Get("token")
+}
+
+// promote grants the admin role to username
+func (s *testServer) promote(username string) {
+	s.t.Helper()
+	user, err := s.store.Users().FindByUsername(username)
+	if err != nil {
+		s.t.Fatalf("failed to find %s: %v", username, err)
+	}
+	user.Role = models.RoleAdmin
+	if err := s.store.Users().Update(user); err != nil {
+		s.t.Fatalf("failed to promote %s: %v", username, err)
+	}
+}
+
+func expect(t *testing.T, resp apiResponse, code int, message string) {
+	t.Helper()
+	if resp.Code != code || resp.Message != message {
+		t.Fatalf("expected %d %q, got %d %q", code, message, resp.Code, resp.Message)
+	}
+}
+
+// expectErrors checks the per-field validation errors of a response
+func expectErrors(t *testing.T, resp apiResponse, fields map[string][]string) {
+	t.Helper()
+	if !reflect.DeepEqual(resp.Errors, fields) {
+		t.Fatalf("expected field errors %v, got %v", fields, resp.Errors)
+	}
+}
+
+// failingStore wraps a Store and makes user writes and audit reads fail
+type failingStore struct {
+	repositories.Store
+}
+
+func (s *failingStore) Users() repositories.UserRepository {
+	return &failingUsers{UserRepository: s.Store.Users()}
+}
+
+func (s *failingStore) Audit() repositories.AuditRepository {
+	return &failingAudit{AuditRepository: s.Store.Audit()}
+}
+
+func (s *failingStore) WithContext(ctx context.Context) repositories.Store {
+	return &failingStore{Store: s.Store.WithContext(ctx)}
+}
+
+func (s *failingStore) Transaction(fn func(tx repositories.Store) error) error {
+	return s.Store.Transaction(func(tx repositories.Store) error {
+		return fn(&failingStore{Store: tx})
+	})
+}
+
+// offlineStore wraps a Store and makes user lookups fail as if the database were down
+type offlineStore struct {
+	repositories.Store
+}
+
+func (s *offlineStore) Users() repositories.UserRepository {
+	return &offlineUsers{UserRepository: s.Store.Users()}
+}
+
+func (s *offlineStore) WithContext(ctx context.Context) repositories.Store {
+	return &offlineStore{Store: s.Store.WithContext(ctx)}
+}
+
+type offlineUsers struct {
+	repositories.UserRepository
+}
+
+func (r *offlineUsers) FindByID(id uuid.UUID) (*models.User, error) {
+	return nil, serviceErrors.ErrInternalServer
+}
+
+type failingUsers struct {
+	repositories.UserRepository
+}
+
+func (r *failingUsers) Update(user *models.User) error { return serviceErrors.ErrInternalServer }
+func (r *failingUsers) Delete(user *models.User) error { return serviceErrors.ErrInternalServer }
+
+type failingAudit struct {
+	repositories.AuditRepository
+}
+
+func (r *failingAudit) ListEvents(afterSequence uint64, limit int) ([]models.AuditEvent, error) {
+	return nil, serviceErrors.ErrInternalServer
+}
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"azyqs-auth-systems/controllers"
+
+	"github.com/gorilla/mux"
+)
+
+// RegisterHealthRoutes defines the unauthenticated liveness and readiness probes
+func RegisterHealthRoutes(router *mux.Router, h *controllers.Handler) {
+	router.HandleFunc("/healthz", h.Liveness).Methods("GET")
+	router.HandleFunc("/readyz", h.Readiness).Methods("GET")
+}
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// RegisterMetricsRoutes exposes the Prometheus metrics at path; router must be the one of the
+// private metrics listener, not the public API router
+func RegisterMetricsRoutes(router *mux.Router, path string) {
+	router.Handle(path, promhttp.Handler()).Methods("GET")
+}
@@ -1,63 +1,118 @@
 package routes
 
 import (
-	"bytes"
+	"azyqs-auth-systems/controllers"
+	serviceErrors "azyqs-auth-systems/errors"
+	"azyqs-auth-systems/i18n"
+	"azyqs-auth-systems/logging"
+	"azyqs-auth-systems/metrics"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ErrorResponse defines the standard error structure
 type ErrorResponse struct {
-	Status  string `json:"status"`
-	Message string `json:"message"`
+	Status           string `json:"status"`
+	Message          string `json:"message"`
+	LocalizedMessage string `json:"localized_message"`
 }
 
+// unmatchedRoute is the route label of requests that matched no route
+const unmatchedRoute = "unmatched"
+
 // methodNotAllowedHandler handles disallowed HTTP methods
 func methodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
-	writeErrorResponse(w, http.StatusMethodNotAllowed, "method_not_allowed")
-	log.Printf("[405] %s %s - Method Not Allowed", r.Method, r.URL.Path)
+	writeErrorResponse(w, r, serviceErrors.ErrMethodNotAllowed)
 }
 
-// notFoundHandler handles undefined routes
+// notFoundHandler handles undefined routes; the router middleware does not run for them
 func notFoundHandler(w http.ResponseWriter, r *http.Request) {
-	writeErrorResponse(w, http.StatusNotFound, "route_not_found")
-	log.Printf("[404] %s %s - Route Not Found", r.Method, r.URL.Path)
+	writeErrorResponse(w, r, serviceErrors.ErrRouteNotFound)
+	metrics.HTTPRequests.WithLabelValues(methodLabel(r.Method), unmatchedRoute, "404").Inc()
+	logging.FromContext(r.Context()).Info("request",
+		"method", r.Method,
+		"path", r.URL.Path,
+		"route", unmatchedRoute,
+		"status", http.StatusNotFound,
+	)
 }
 
-// writeErrorResponse writes a standardized JSON error response
-func writeErrorResponse(w http.ResponseWriter, statusCode int, message string) {
+// writeErrorResponse writes the standardized JSON response for an API error
+func writeErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr, _ := serviceErrors.ToAPIError(err)
+	localized := i18n.Localize(w, r, apiErr.Code)
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
+	w.WriteHeader(apiErr.Status)
 	json.NewEncoder(w).Encode(ErrorResponse{
-		Status:  "error",
-		Message: message,
+		Status:           "error",
+		Message:          apiErr.Code,
+		LocalizedMessage: localized,
 	})
 }
 
-// loggingMiddleware logs all incoming requests
+// loggingMiddleware logs all incoming requests, records their count and latency
+// and names their trace span
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		lrw := &loggingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK, body: &bytes.Buffer{}}
+		lrw := &loggingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 		startTime := time.Now()
 
 		next.ServeHTTP(lrw, r)
 
 		duration := time.Since(startTime)
 
-		log.Printf("[%d] %s %s - %s - Duration: %s",
-			lrw.statusCode, r.Method, r.URL.Path, http.StatusText(lrw.statusCode), duration)
+		route := unmatchedRoute
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		// Name the server span after the route template rather than the raw path
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(semconv.HTTPRoute(route))
+
+		status := strconv.Itoa(lrw.statusCode)
+		metrics.HTTPRequests.WithLabelValues(methodLabel(r.Method), route, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(methodLabel(r.Method), route, status).Observe(duration.Seconds())
+
+		level := slog.LevelInfo
+		if lrw.statusCode >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logging.FromContext(r.Context()).Log(r.Context(), level, "request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"route", route,
+			"status", lrw.statusCode,
+			"bytes", lrw.bytes,
+			"duration_ms", float64(duration.Microseconds())/1000,
+		)
 	})
 }
 
-// loggingResponseWriter is a wrapper to capture status codes and response body
+// methodLabel keeps the method label bounded; clients can send any method token
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions:
+		return method
+	}
+	return "OTHER"
+}
+
+// loggingResponseWriter is a wrapper to capture status codes and response size
 type loggingResponseWriter struct {
 	http.ResponseWriter
 	statusCode int
-	body       *bytes.Buffer
+	bytes      int
 }
 
 func (lrw *loggingResponseWriter) WriteHeader(code int) {
@@ -66,17 +121,21 @@ func (lrw *loggingResponseWriter) WriteHeader(code int) {
 }
 
 func (lrw *loggingResponseWriter) Write(b []byte) (int, error) {
-	lrw.body.Write(b)
-	return lrw.ResponseWriter.Write(b)
+	n, err := lrw.ResponseWriter.Write(b)
+	lrw.bytes += n
+	return n, err
 }
 
 // RegisterRoutes defines all API endpoints
-func RegisterRoutes(router *mux.Router) {
+func RegisterRoutes(router *mux.Router, h *controllers.Handler) {
 	router.Use(loggingMiddleware)
 
-	// Register Auth and User Routes
-	RegisterAuthRoutes(router)
-	RegisterUserRoutes(router)
+	// Register Health, Auth, User, Audit and Admin Routes
+	RegisterHealthRoutes(router, h)
+	RegisterAuthRoutes(router, h)
+	RegisterUserRoutes(router, h)
+	RegisterAuditRoutes(router, h)
+	RegisterAdminRoutes(router, h)
 
 	// Custom 404 Not Found Handler
 	router.NotFoundHandler = http.HandlerFunc(notFoundHandler)
@@ -0,0 +1,900 @@
+package routes_test
+
+import (
+	"azyqs-auth-systems/breach"
+	"azyqs-auth-systems/controllers"
+	serviceErrors "azyqs-auth-systems/errors"
+	"azyqs-auth-systems/metrics"
+	"azyqs-auth-systems/middlewares"
+	"azyqs-auth-systems/models"
+	"azyqs-auth-systems/repositories"
+	"azyqs-auth-systems/routes"
+	"azyqs-auth-systems/services"
+	"azyqs-auth-systems/utils"
+	"azyqs-auth-systems/validators"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRegister(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		s.register("alice", "alice@example.com")
+
+		tests := []struct {
+			name    string
+			body    string
+			code    int
+			message string
+			fields  map[string][]string
+		}{
+			{"duplicate username", `{"username":"alice","name":"Alice","email":"other@example.com","password":"Passw0rd!"}`, http.StatusBadRequest, "duplicate_record", nil},
+			{"duplicate email", `{"username":"bob","name":"Bob","email":"alice@example.com","password":"Passw0rd!"}`, http.StatusBadRequest, "duplicate_record", nil},
+			{"duplicate username in another case", `{"username":"Alice","name":"Alice","email":"other@example.com","password":"Passw0rd!"}`, http.StatusBadRequest, "duplicate_record", nil},
+			{"duplicate email in another case", `{"username":"bob","name":"Bob","email":"ALICE@Example.com","password":"Passw0rd!"}`, http.StatusBadRequest, "duplicate_record", nil},
+			{"invalid json", `{"username":`, http.StatusBadRequest, "invalid_input", nil},
+			{"invalid username", `{"username":"a","name":"Bob","email":"bob@example.com","password":"Passw0rd!"}`, http.StatusBadRequest, "validation_failed", map[string][]string{"username": {"username_too_short"}}},
+			{"invalid name", `{"username":"bob","name":"B","email":"bob@example.com","password":"Passw0rd!"}`, http.StatusBadRequest, "validation_failed", map[string][]string{"name": {"name_too_short"}}},
+			{"invalid email", `{"username":"bob","name":"Bob","email":"bob","password":"Passw0rd!"}`, http.StatusBadRequest, "validation_failed", map[string][]string{"email": {"invalid_email_format"}}},
+			{"weak password", `{"username":"bob","name":"Bob","email":"bob@example.com","password":"password"}`, http.StatusBadRequest, "validation_failed", map[string][]string{"password": {"password_missing_uppercase", "password_missing_digit", "password_missing_special", "password_too_weak"}}},
+			{"personal password", `{"username":"bob","name":"Bob","email":"bob@example.com","password":"Bob#Passw0rd"}`, http.StatusBadRequest, "validation_failed", map[string][]string{"password": {"password_contains_personal_info"}}},
+			{"every field invalid", `{"username":"a","name":"B","email":"bob","password":"Sh0rt!"}`, http.StatusBadRequest, "validation_failed", map[string][]string{
+				"username": {"username_too_short"},
+				"name":     {"name_too_short"},
+				"email":    {"invalid_email_format"},
+				"password": {"password_too_short", "password_too_weak"},
+			}},
+			{"too long password", `{"username":"bob","name":"Bob","email":"bob@example.com","password":"Passw0rd!` + strings.Repeat("xy", 40) + `"}`, http.StatusBadRequest, "validation_failed", map[string][]string{"password": {"password_too_long"}}},
+			// 49 characters fit max_length, but 89 bytes do not fit bcrypt
+			{"too many bytes for bcrypt", `{"username":"bob","name":"Bob","email":"bob@example.com","password":"Passw0rd!` + strings.Repeat("äöüß", 10) + `"}`, http.StatusBadRequest, "validation_failed", map[string][]string{"password": {"password_too_long"}}},
+			{"multibyte password", `{"username":"bob","name":"Bob","email":"bob@example.com","password":"Passw0rd!` + strings.Repeat("äöüß", 5) + `"}`, http.StatusOK, "registration_successful", nil},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				resp := s.do("POST", "/auth/register", tt.body, "")
+				expect(t, resp, tt.code, tt.message)
+				expectErrors(t, resp, tt.fields)
+			})
+		}
+	})
+}
+
+func TestLogin(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		s.register("alice", "alice@example.com")
+		s.login("alice", testPassword)
+
+		tests := []struct {
+			name    string
+			body    string
+			code    int
+			message string
+			fields  map[string][]string
+		}{
+			{"unknown user", `{"username":"nobody","password":"Passw0rd!"}`, http.StatusUnauthorized, "unauthorized", nil},
+			{"wrong password", `{"username":"alice","password":"Wr0ngPass!"}`, http.StatusUnauthorized, "unauthorized", nil},
+			{"unknown email", `{"email":"nobody@example.com","password":"Passw0rd!"}`, http.StatusUnauthorized, "unauthorized", nil},
+			{"wrong password by email", `{"email":"alice@example.com","password":"Wr0ngPass!"}`, http.StatusUnauthorized, "unauthorized", nil},
+			{"invalid email", `{"email":"alice","password":"Passw0rd!"}`, http.StatusBadRequest, "validation_failed", map[string][]string{"email": {"invalid_email_format"}}},
+			{"invalid json", `not json`, http.StatusBadRequest, "invalid_input", nil},
+			{"invalid username", `{"username":"a","password":"Passw0rd!"}`, http.StatusBadRequest, "validation_failed", map[string][]string{"username": {"username_too_short"}}},
+			{"password predating the policy", `{"username":"alice","password":"short"}`, http.StatusUnauthorized, "unauthorized", nil},
+			{"missing password", `{"username":"alice","password":""}`, http.StatusBadRequest, "validation_failed", map[string][]string{"password": {"password_required"}}},
+			{"both invalid", `{"username":"a","password":""}`, http.StatusBadRequest, "validation_failed", map[string][]string{"username": {"username_too_short"}, "password": {"password_required"}}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				resp := s.do("POST", "/auth/login", tt.body, "")
+				expect(t, resp, tt.code, tt.message)
+				expectErrors(t, resp, tt.fields)
+			})
+		}
+	})
+}
+
+func TestCaseInsensitiveIdentifiers(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		s.register("Alice", "Alice@Example.com")
+		s.register("bob", "bob@example.com")
+
+		token := s.login("ALICE", testPassword)
+		expect(t, s.do("POST", "/auth/login", `{"email":"alice@EXAMPLE.com","password":"Passw0rd!"}`, ""), http.StatusOK, "login_successful")
+
+		// Usernames keep their case while emails are stored lowercased
+		resp := s.do("GET", "/user/profile", "", token)
+		var profile map[string]interface{}
+		if err := json.Unmarshal(resp.Data, &profile); err != nil {
+			t.Fatalf("invalid profile: %v", err)
+		}
+		if profile["username"] != "Alice" || profile["email"] != "alice@example.com" {
+			t.Fatalf("unexpected profile: %v", profile)
+		}
+
+		bob := s.login("bob", testPassword)
+		elevated := s.reauthenticate(bob, testPassword)
+		expect(t, s.do("PUT", "/user/profile", `{"username":"ALICE"}`, elevated), http.StatusBadRequest, "username_already_taken")
+		expect(t, s.do("PUT", "/user/email", `{"email":"ALICE@example.com","password":"Passw0rd!"}`, bob), http.StatusBadRequest, "email_already_taken")
+		expect(t, s.do("PUT", "/user/profile", `{"username":"Bob","email":"Bob@Example.com"}`, elevated), http.StatusOK, "profile_updated")
+	})
+}
+
+func TestAuthentication(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		expect(t, s.do("GET", "/user/profile", "", ""), http.StatusForbidden, "token_not_found")
+		expect(t, s.do("GET", "/user/profile", "", "not.a.jwt"), http.StatusForbidden, "token_invalid")
+
+		req := httptest.NewRequest("GET", "/user/profile", nil)
+		req.Header.Set("Authorization", "Bearer")
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "token_invalid_format") {
+			t.Fatalf("expected token_invalid_format, got %d %s", rec.Code, rec.Body.String())
+		}
+	})
+}
+
+func TestProfile(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		s.register("alice", "alice@example.com")
+		s.register("bob", "bob@example.com")
+		token := s.login("alice", testPassword)
+
+		resp := s.do("GET", "/user/profile", "", token)
+		expect(t, resp, http.StatusOK, "profile_found")
+		var profile map[string]interface{}
+		if err := json.Unmarshal(resp.Data, &profile); err != nil {
+			t.Fatalf("invalid profile: %v", err)
+		}
+		if profile["username"] != "alice" || profile["email"] != "alice@example.com" {
+			t.Fatalf("unexpected profile: %v", profile)
+		}
+		if _, ok := profile["password"]; ok {
+			t.Fatal("profile must not expose the password hash")
+		}
+
+		edits := []struct {
+			name    string
+			body    string
+			code    int
+			message string
+			fields  map[string][]string
+		}{
+			{"username taken", `{"username":"bob","name":"Alice","email":"alice@example.com"}`, http.StatusBadRequest, "username_already_taken", nil},
+			{"email changed", `{"username":"alice","name":"Alice","email":"alice2@example.com"}`, http.StatusBadRequest, "email_change_requires_confirmation", nil},
+			{"invalid json", `{`, http.StatusBadRequest, "invalid_input", nil},
+			{"invalid username", `{"username":"a_b"}`, http.StatusBadRequest, "validation_failed", map[string][]string{"username": {"invalid_username_format"}}},
+			{"invalid name", `{"name":"A"}`, http.StatusBadRequest, "validation_failed", map[string][]string{"name": {"name_too_short"}}},
+			{"invalid email", `{"email":"nope"}`, http.StatusBadRequest, "validation_failed", map[string][]string{"email": {"invalid_email_format"}}},
+			{"invalid name and email", `{"username":"alice","name":"A","email":"nope"}`, http.StatusBadRequest, "validation_failed", map[string][]string{"name": {"name_too_short"}, "email": {"invalid_email_format"}}},
+			{"success", `{"username":"alice.l","name":"Alice Liddell","email":"Alice@example.com"}`, http.StatusOK, "profile_updated", nil},
+		}
+		elevated := s.reauthenticate(token, testPassword)
+		for _, tt := range edits {
+			t.Run("edit "+tt.name, func(t *testing.T) {
+				resp := s.do("PUT", "/user/profile", tt.body, elevated)
+				expect(t, resp, tt.code, tt.message)
+				expectErrors(t, resp, tt.fields)
+			})
+		}
+
+		resp = s.do("GET", "/user/profile", "", token)
+		if err := json.Unmarshal(resp.Data, &profile); err != nil || profile["username"] != "alice.l" {
+			t.Fatalf("profile was not updated: %s", resp.Data)
+		}
+
+		// Fields left out of an edit keep their stored value
+		expect(t, s.do("PUT", "/user/profile", `{}`, elevated), http.StatusOK, "profile_updated")
+		expect(t, s.do("PUT", "/user/profile", `{"name":"Alice L."}`, elevated), http.StatusOK, "profile_updated")
+		expect(t, s.do("PUT", "/user/profile", `{"username":"alice.liddell","name":""}`, elevated), http.StatusOK, "profile_updated")
+		resp = s.do("GET", "/user/profile", "", token)
+		if err := json.Unmarshal(resp.Data, &profile); err != nil ||
+			profile["username"] != "alice.liddell" || profile["name"] != "Alice L." || profile["email"] != "alice@example.com" {
+			t.Fatalf("partial edits changed other fields: %s", resp.Data)
+		}
+
+		expect(t, s.do("DELETE", "/user/profile", `{"password":"Wr0ngPass!"}`, token), http.StatusBadRequest, "password_mismatch")
+		resp = s.do("DELETE", "/user/profile", `{"password":""}`, token)
+		expect(t, resp, http.StatusBadRequest, "validation_failed")
+		expectErrors(t, resp, map[string][]string{"password": {"password_required"}})
+		expect(t, s.do("DELETE", "/user/profile", `[]`, token), http.StatusBadRequest, "invalid_input")
+		expect(t, s.do("DELETE", "/user/profile", `{"password":"Passw0rd!"}`, token), http.StatusOK, "profile_deleted")
+
+		expect(t, s.do("GET", "/user/profile", "", token), http.StatusForbidden, "token_revoked")
+		expect(t, s.do("POST", "/auth/login", `{"username":"alice.liddell","password":"Passw0rd!"}`, ""), http.StatusUnauthorized, "unauthorized")
+	})
+}
+
+func TestEmailChange(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		s.register("alice", "alice@example.com")
+		s.register("bob", "bob@example.com")
+		token := s.login("alice", testPassword)
+
+		requests := []struct {
+			name    string
+			body    string
+			code    int
+			message string
+			fields  map[string][]string
+		}{
+			{"invalid json", `{`, http.StatusBadRequest, "invalid_input", nil},
+			{"invalid fields", `{"email":"nope","password":""}`, http.StatusBadRequest, "validation_failed", map[string][]string{"email": {"invalid_email_format"}, "password": {"password_required"}}},
+			{"wrong password", `{"email":"new@example.com","password":"Wr0ngPass!"}`, http.StatusBadRequest, "password_mismatch", nil},
+			{"same email", `{"email":"ALICE@example.com","password":"Passw0rd!"}`, http.StatusBadRequest, "email_unchanged", nil},
+			{"email taken", `{"email":"bob@example.com","password":"Passw0rd!"}`, http.StatusBadRequest, "email_already_taken", nil},
+		}
+		for _, tt := range requests {
+			t.Run(tt.name, func(t *testing.T) {
+				resp := s.do("PUT", "/user/email", tt.body, token)
+				expect(t, resp, tt.code, tt.message)
+				expectErrors(t, resp, tt.fields)
+			})
+		}
+
+		change := func(email string) {
+			t.Helper()
+			resp := s.do("PUT", "/user/email", `{"email":"`+email+`","password":"Passw0rd!"}`, token)
+			expect(t, resp, http.StatusAccepted, "email_change_requested")
+		}
+		// Opening an emailed link only returns the request that finishes the step
+		open := func(link string) apiResponse {
+			t.Helper()
+			resp := s.do("GET", link, "", "")
+			expect(t, resp, http.StatusOK, "email_link_opened")
+			var step struct {
+				Method string `json:"method"`
+				Action string `json:"action"`
+				Token  string `json:"token"`
+			}
+			if err := json.Unmarshal(resp.Data, &step); err != nil || step.Token != s.linkToken(link) {
+				t.Fatalf("unexpected email link step: %s", resp.Data)
+			}
+			return s.do(step.Method, step.Action, `{"token":"`+step.Token+`"}`, "")
+		}
+		confirm := func(token string) apiResponse {
+			return s.do("POST", "/auth/email-change/confirm", `{"token":"`+token+`"}`, "")
+		}
+		cancel := func(token string) apiResponse {
+			return s.do("POST", "/auth/email-change/cancel", `{"token":"`+token+`"}`, "")
+		}
+
+		// A newer request replaces the pending one
+		change("first@example.com")
+		replaced := s.emailLink("first@example.com", "confirm")
+		change("New@Example.com")
+		expect(t, open(replaced), http.StatusBadRequest, "email_change_token_invalid")
+		expect(t, confirm(s.linkToken(s.emailLink("alice@example.com", "cancel"))), http.StatusBadRequest, "email_change_token_invalid")
+		expect(t, confirm("nonsense"), http.StatusBadRequest, "email_change_token_invalid")
+		for _, resp := range []apiResponse{s.do("GET", "/auth/email-change/confirm", "", ""), confirm(""), s.do("POST", "/auth/email-change/cancel", `{}`, "")} {
+			expect(t, resp, http.StatusBadRequest, "validation_failed")
+			expectErrors(t, resp, map[string][]string{"token": {"token_required"}})
+		}
+		s.login("alice", testPassword)
+
+		// Links opened by a mail scanner change nothing
+		confirmed := s.emailLink("new@example.com", "confirm")
+		s.do("GET", confirmed, "", "")
+		s.do("GET", s.emailLink("alice@example.com", "cancel"), "", "")
+		expect(t, s.do("POST", "/auth/login", `{"email":"new@example.com","password":"Passw0rd!"}`, ""), http.StatusUnauthorized, "unauthorized")
+		expect(t, open(confirmed), http.StatusOK, "email_changed")
+		expect(t, open(confirmed), http.StatusBadRequest, "email_change_token_invalid")
+		expect(t, s.do("POST", "/auth/login", `{"email":"new@example.com","password":"Passw0rd!"}`, ""), http.StatusOK, "login_successful")
+		expect(t, s.do("POST", "/auth/login", `{"email":"alice@example.com","password":"Passw0rd!"}`, ""), http.StatusUnauthorized, "unauthorized")
+
+		// The previous address can still undo a confirmed change, which revokes every session
+		resp := s.do("PUT", "/user/email", `{"email":"other@example.com","password":"Passw0rd!"}`, token)
+		expect(t, resp, http.StatusConflict, "email_change_locked")
+		undo := s.emailLink("alice@example.com", "cancel")
+		expect(t, open(undo), http.StatusOK, "email_change_cancelled")
+		expect(t, open(undo), http.StatusBadRequest, "email_change_token_invalid")
+		expect(t, s.do("GET", "/user/profile", "", token), http.StatusForbidden, "token_revoked")
+		expect(t, s.do("POST", "/auth/login", `{"email":"new@example.com","password":"Passw0rd!"}`, ""), http.StatusUnauthorized, "unauthorized")
+		token = s.login("alice", testPassword)
+
+		// The current address can cancel a change it did not ask for
+		change("stolen@example.com")
+		expect(t, open(s.emailLink("alice@example.com", "cancel")), http.StatusOK, "email_change_cancelled")
+		expect(t, open(s.emailLink("stolen@example.com", "confirm")), http.StatusBadRequest, "email_change_token_invalid")
+		expect(t, cancel("nonsense"), http.StatusBadRequest, "email_change_token_invalid")
+		s.login("alice", testPassword)
+
+		// The token can also be posted, and the new address may be taken before it is confirmed
+		change("carol@example.com")
+		s.register("carol", "carol@example.com")
+		expect(t, confirm(s.linkToken(s.emailLink("carol@example.com", "confirm"))), http.StatusBadRequest, "email_already_taken")
+
+		t.Run("expired", func(t *testing.T) {
+			defer func(ttl time.Duration) { services.EmailChangeTTL = ttl }(services.EmailChangeTTL)
+			services.EmailChangeTTL = -time.Minute
+			change("late@example.com")
+			expect(t, open(s.emailLink("late@example.com", "confirm")), http.StatusBadRequest, "email_change_token_invalid")
+		})
+
+		resp = s.do("GET", "/user/profile", "", token)
+		var profile map[string]interface{}
+		if err := json.Unmarshal(resp.Data, &profile); err != nil || profile["email"] != "alice@example.com" {
+			t.Fatalf("unexpected profile: %s", resp.Data)
+		}
+	})
+}
+
+func TestReauthentication(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		s.register("alice", "alice@example.com")
+		token := s.login("alice", testPassword)
+
+		// However fresh, a login token is not an elevated one
+		expect(t, s.do("PUT", "/user/profile", `{"username":"alice","name":"Alice"}`, token), http.StatusUnauthorized, "reauthentication_required")
+
+		user, err := s.store.Users().FindByUsername("alice")
+		if err != nil {
+			t.Fatalf("failed to load alice: %v", err)
+		}
+		sign := func(claims jwt.MapClaims) string {
+			t.Helper()
+			keyID, secret := utils.Keys.Active()
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+			if keyID != "" {
+				token.Header["kid"] = keyID
+			}
+			signed, err := token.SignedString(secret)
+			if err != nil {
+				t.Fatalf("failed to sign token: %v", err)
+			}
+			return signed
+		}
+		exp := time.Now().Add(time.Hour).Unix()
+		stale := sign(jwt.MapClaims{"user_id": user.ID.String(), "ver": 0, "auth_time": time.Now().Add(-time.Hour).Unix(), "exp": exp})
+		legacy := sign(jwt.MapClaims{"user_id": user.ID.String(), "ver": 0, "exp": exp})
+		staleElevated := sign(jwt.MapClaims{"user_id": user.ID.String(), "ver": 0, "elevated": true, "auth_time": time.Now().Add(-time.Hour).Unix(), "exp": exp})
+
+		for name, token := range map[string]string{"login": token, "stale": stale, "legacy": legacy, "stale elevated": staleElevated} {
+			t.Run(name, func(t *testing.T) {
+				expect(t, s.do("GET", "/user/profile", "", token), http.StatusOK, "profile_found")
+				expect(t, s.do("PUT", "/user/profile", `{"username":"alice","name":"Mallory"}`, token), http.StatusUnauthorized, "reauthentication_required")
+			})
+		}
+
+		attempts := []struct {
+			name    string
+			token   string
+			body    string
+			code    int
+			message string
+			fields  map[string][]string
+		}{
+			{"no token", "", `{"password":"Passw0rd!"}`, http.StatusForbidden, "token_not_found", nil},
+			{"invalid json", stale, `{`, http.StatusBadRequest, "invalid_input", nil},
+			{"missing password", stale, `{"password":""}`, http.StatusBadRequest, "validation_failed", map[string][]string{"password": {"password_required"}}},
+			{"wrong password", stale, `{"password":"Wr0ngPass!"}`, http.StatusBadRequest, "invalid_password", nil},
+		}
+		for _, tt := range attempts {
+			t.Run(tt.name, func(t *testing.T) {
+				resp := s.do("POST", "/auth/reauthenticate", tt.body, tt.token)
+				expect(t, resp, tt.code, tt.message)
+				expectErrors(t, resp, tt.fields)
+			})
+		}
+
+		elevated := s.reauthenticate(stale, testPassword)
+		expect(t, s.do("PUT", "/user/profile", `{"username":"alice","name":"Alice Liddell"}`, elevated), http.StatusOK, "profile_updated")
+
+		// Restricted tokens cannot be traded for a full one
+		if err := s.handler.Users.ExpirePassword(context.Background(), user.ID); err != nil {
+			t.Fatalf("failed to expire the password: %v", err)
+		}
+		restricted := s.loginToChangePassword("alice", testPassword)
+		expect(t, s.do("POST", "/auth/reauthenticate", `{"password":"Passw0rd!"}`, restricted), http.StatusForbidden, "password_change_required")
+	})
+}
+
+func TestChangePassword(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		s.register("alice", "alice@example.com")
+		token := s.login("alice", testPassword)
+
+		tests := []struct {
+			name    string
+			body    string
+			code    int
+			message string
+			fields  map[string][]string
+		}{
+			{"invalid json", `{`, http.StatusBadRequest, "invalid_input", nil},
+			{"missing old password", `{"old_password":"","new_password":"N3wPassw0rd!","confirm_new_password":"N3wPassw0rd!"}`, http.StatusBadRequest, "validation_failed", map[string][]string{"old_password": {"password_required"}}},
+			{"weak new password", `{"old_password":"Passw0rd!","new_password":"weakpassword1","confirm_new_password":"weakpassword1"}`, http.StatusBadRequest, "validation_failed", map[string][]string{"new_password": {"password_missing_uppercase", "password_missing_special"}}},
+			{"personal new password", `{"old_password":"Passw0rd!","new_password":"Alice#2024x","confirm_new_password":"Alice#2024x"}`, http.StatusBadRequest, "validation_failed", map[string][]string{"new_password": {"password_contains_personal_info"}}},
+			{"confirmation mismatch", `{"old_password":"Passw0rd!","new_password":"N3wPassw0rd!","confirm_new_password":"N3wPassw0rd?"}`, http.StatusBadRequest, "validation_failed", map[string][]string{"confirm_new_password": {"password_mismatch"}}},
+			{"weak and mismatched", `{"old_password":"","new_password":"weakpassword1","confirm_new_password":"other"}`, http.StatusBadRequest, "validation_failed", map[string][]string{
+				"old_password":         {"password_required"},
+				"new_password":         {"password_missing_uppercase", "password_missing_special"},
+				"confirm_new_password": {"password_mismatch"},
+			}},
+			{"wrong old password", `{"old_password":"Wr0ngPass!","new_password":"N3wPassw0rd!","confirm_new_password":"N3wPassw0rd!"}`, http.StatusBadRequest, "invalid_password", nil},
+			{"success", `{"old_password":"Passw0rd!","new_password":"N3wPassw0rd!","confirm_new_password":"N3wPassw0rd!"}`, http.StatusOK, "password_changed", nil},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				resp := s.do("PUT", "/user/change-password", tt.body, token)
+				expect(t, resp, tt.code, tt.message)
+				expectErrors(t, resp, tt.fields)
+			})
+		}
+
+		expect(t, s.do("POST", "/auth/login", `{"username":"alice","password":"Passw0rd!"}`, ""), http.StatusUnauthorized, "unauthorized")
+		s.login("alice", "N3wPassw0rd!")
+	})
+}
+
+func TestSessionRevocation(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		s.register("alice", "alice@example.com")
+		s.register("bob", "bob@example.com")
+		alice, _ := s.handler.Users.GetUserByUsername(context.Background(), "alice")
+		bob, _ := s.handler.Users.GetUserByUsername(context.Background(), "bob")
+
+		token := s.login("alice", testPassword)
+		if err := s.handler.Users.RevokeSessions(context.Background(), alice.ID); err != nil {
+			t.Fatalf("RevokeSessions failed: %v", err)
+		}
+		expect(t, s.do("GET", "/user/profile", "", token), http.StatusForbidden, "token_revoked")
+		expect(t, s.do("GET", "/user/profile", "", s.login("alice", testPassword)), http.StatusOK, "profile_found")
+
+		token = s.login("alice", testPassword)
+		if err := s.handler.Users.SetUserPassword(context.Background(), alice.ID, "Res3tPassw0rd!", false); err != nil {
+			t.Fatalf("SetUserPassword failed: %v", err)
+		}
+		expect(t, s.do("GET", "/user/profile", "", token), http.StatusForbidden, "token_revoked")
+		s.login("alice", "Res3tPassw0rd!")
+
+		token = s.login("bob", testPassword)
+		if err := s.handler.Users.DisableUser(context.Background(), bob.ID); err != nil {
+			t.Fatalf("DisableUser failed: %v", err)
+		}
+		expect(t, s.do("GET", "/user/profile", "", token), http.StatusForbidden, "token_revoked")
+		expect(t, s.do("POST", "/auth/login", `{"username":"bob","password":"Passw0rd!"}`, ""), http.StatusForbidden, "user_disabled")
+	})
+}
+
+func TestGrantRole(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		s.register("alice", "alice@example.com")
+		alice, _ := s.handler.Users.GetUserByUsername(context.Background(), "alice")
+		token := s.login("alice", testPassword)
+
+		if err := s.handler.Users.GrantRole(context.Background(), alice.ID, "root"); err != serviceErrors.ErrInvalidRole {
+			t.Fatalf("expected invalid_role, got %v", err)
+		}
+		expect(t, s.do("GET", "/admin/webhooks", "", token), http.StatusForbidden, "forbidden")
+
+		if err := s.handler.Users.GrantRole(context.Background(), alice.ID, models.RoleAdmin); err != nil {
+			t.Fatalf("GrantRole failed: %v", err)
+		}
+		expect(t, s.do("GET", "/admin/webhooks", "", token), http.StatusOK, "webhooks_found")
+
+		if err := s.handler.Users.GrantRole(context.Background(), uuid.New(), models.RoleAdmin); err != serviceErrors.ErrUserNotFound {
+			t.Fatalf("expected user_not_found, got %v", err)
+		}
+	})
+}
+
+func TestStoreFailures(t *testing.T) {
+	for name, newStore := range backends {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			s := newTestServer(t, store)
+			s.register("alice", "alice@example.com")
+			s.promote("alice")
+			token := s.login("alice", testPassword)
+			elevated := s.reauthenticate(token, testPassword)
+
+			broken := newTestServer(t, &failingStore{Store: store})
+			// Store failures are internal and must not be echoed to clients
+			expect(t, broken.do("PUT", "/user/profile", `{"name":"Alice"}`, elevated), http.StatusInternalServerError, "internal_server_error")
+			expect(t, broken.do("PUT", "/user/change-password", `{"old_password":"Passw0rd!","new_password":"N3wPassw0rd!","confirm_new_password":"N3wPassw0rd!"}`, token), http.StatusInternalServerError, "internal_server_error")
+			expect(t, broken.do("DELETE", "/user/profile", `{"password":"Passw0rd!"}`, token), http.StatusInternalServerError, "internal_server_error")
+			expect(t, broken.do("GET", "/audit/verify", "", token), http.StatusInternalServerError, "audit_verify_failed")
+
+			// An unreachable database must not look like a revoked session
+			offline := newTestServer(t, &offlineStore{Store: store})
+			expect(t, offline.do("GET", "/user/profile", "", token), http.StatusInternalServerError, "internal_server_error")
+		})
+	}
+}
+
+func TestMissingUserContext(t *testing.T) {
+	h := newTestServer(t, repositories.NewMemoryStore()).handler
+	handlers := map[string]http.HandlerFunc{
+		"view":            h.ViewProfile,
+		"edit":            h.EditProfile,
+		"delete":          h.DeleteProfile,
+		"change password": h.ChangePassword,
+	}
+
+	for name, handler := range handlers {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler(rec, httptest.NewRequest("GET", "/", nil))
+			assertBody(t, rec, http.StatusUnauthorized, "user_id_not_found")
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req = req.WithContext(context.WithValue(req.Context(), middlewares.UserIDKey, "not-a-uuid"))
+			rec = httptest.NewRecorder()
+			handler(rec, req)
+			assertBody(t, rec, http.StatusBadRequest, "user_id_is_invalid")
+		})
+	}
+}
+
+func TestRoutingErrors(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		expect(t, s.do("GET", "/does-not-exist", "", ""), http.StatusNotFound, "route_not_found")
+		expect(t, s.do("GET", "/auth/login", "", ""), http.StatusMethodNotAllowed, "method_not_allowed")
+	})
+}
+
+func TestAudit(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		s.register("alice", "alice@example.com")
+		token := s.login("alice", testPassword)
+		s.do("POST", "/auth/login", `{"username":"alice","password":"Wr0ngPass!"}`, "")
+
+		// Walking the whole chain is expensive, so only administrators may start it
+		expect(t, s.do("GET", "/audit/verify", "", token), http.StatusForbidden, "forbidden")
+		s.promote("alice")
+
+		resp := s.do("GET", "/audit/verify", "", token)
+		expect(t, resp, http.StatusOK, "audit_chain_valid")
+
+		var result struct {
+			Valid         bool `json:"valid"`
+			CheckedEvents int  `json:"checked_events"`
+		}
+		if err := json.Unmarshal(resp.Data, &result); err != nil {
+			t.Fatalf("invalid verification result: %v", err)
+		}
+		if !result.Valid || result.CheckedEvents != 3 {
+			t.Fatalf("expected 3 valid events, got %+v", result)
+		}
+	})
+}
+
+func TestAdminWebhooks(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		s.register("alice", "alice@example.com")
+		s.register("admin", "admin@example.com")
+		s.promote("admin")
+		userToken := s.login("alice", testPassword)
+		adminToken := s.login("admin", testPassword)
+
+		expect(t, s.do("GET", "/admin/webhooks", "", userToken), http.StatusForbidden, "forbidden")
+
+		expect(t, s.do("POST", "/admin/webhooks", `{"url":"ftp://x","events":["*"]}`, adminToken), http.StatusBadRequest, "invalid_webhook_url")
+		expect(t, s.do("POST", "/admin/webhooks", `{"url":"https://hooks.example.com","events":["user.exploded"]}`, adminToken), http.StatusBadRequest, "unknown_webhook_event")
+		expect(t, s.do("POST", "/admin/webhooks", `{`, adminToken), http.StatusBadRequest, "invalid_input")
+
+		resp := s.do("POST", "/admin/webhooks", `{"url":"https://hooks.example.com","events":["user.registered"]}`, adminToken)
+		expect(t, resp, http.StatusCreated, "webhook_created")
+		var created struct {
+			Webhook struct {
+				ID string `json:"id"`
+			} `json:"webhook"`
+			Secret string `json:"secret"`
+		}
+		if err := json.Unmarshal(resp.Data, &created); err != nil || created.Secret == "" {
+			t.Fatalf("webhook creation must return a secret: %s", resp.Data)
+		}
+
+		expect(t, s.do("GET", "/admin/webhooks", "", adminToken), http.StatusOK, "webhooks_found")
+		expect(t, s.do("GET", "/admin/webhooks/"+created.Webhook.ID+"/deliveries", "", adminToken), http.StatusOK, "deliveries_found")
+		expect(t, s.do("GET", "/admin/webhooks/"+created.Webhook.ID+"/deliveries?limit=0", "", adminToken), http.StatusBadRequest, "invalid_input")
+		expect(t, s.do("DELETE", "/admin/webhooks/"+created.Webhook.ID, "", adminToken), http.StatusOK, "webhook_deleted")
+
+		missing := "00000000-0000-0000-0000-000000000001"
+		expect(t, s.do("DELETE", "/admin/webhooks/"+missing, "", adminToken), http.StatusNotFound, "webhook_not_found")
+		expect(t, s.do("GET", "/admin/webhooks/"+missing+"/deliveries", "", adminToken), http.StatusNotFound, "webhook_not_found")
+		expect(t, s.do("POST", "/admin/webhooks/deliveries/"+missing+"/retry", "", adminToken), http.StatusNotFound, "delivery_not_found")
+	})
+}
+
+func assertBody(t *testing.T, rec *httptest.ResponseRecorder, code int, message string) {
+	t.Helper()
+	var resp controllers.Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON response %q: %v", rec.Body.String(), err)
+	}
+	if rec.Code != code || resp.Message != message {
+		t.Fatalf("expected %d %q, got %d %q", code, message, rec.Code, resp.Message)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		expect(t, s.do("GET", "/healthz", "", ""), http.StatusOK, "alive")
+
+		resp := s.do("GET", "/readyz", "", "")
+		expect(t, resp, http.StatusOK, "ready")
+		var report struct {
+			Ready  bool              `json:"ready"`
+			Checks []json.RawMessage `json:"checks"`
+		}
+		if err := json.Unmarshal(resp.Data, &report); err != nil || !report.Ready || report.Checks == nil {
+			t.Fatalf("unexpected readiness report %s (%v)", resp.Data, err)
+		}
+
+		s.handler.Health.SetShuttingDown()
+		expect(t, s.do("GET", "/readyz", "", ""), http.StatusServiceUnavailable, "shutting_down")
+		expect(t, s.do("GET", "/healthz", "", ""), http.StatusOK, "alive")
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		// Metrics live on their own listener, never on the public API router
+		expect(t, s.do("GET", "/metrics", "", ""), http.StatusNotFound, "route_not_found")
+		metricsRouter := mux.NewRouter()
+		routes.RegisterMetricsRoutes(metricsRouter, "/metrics")
+		logins := metrics.HTTPRequests.WithLabelValues("POST", "/auth/login", "200")
+		before := testutil.ToFloat64(logins)
+		successes := testutil.ToFloat64(metrics.Logins.WithLabelValues("success", ""))
+		wrongPassword := testutil.ToFloat64(metrics.Logins.WithLabelValues("failure", "invalid_password"))
+		unmatched := testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("GET", "unmatched", "404"))
+		malformed := testutil.ToFloat64(metrics.TokenValidationFailures.WithLabelValues("token_malformed"))
+
+		s.register("alice", "alice@example.com")
+		s.login("alice", testPassword)
+		s.do("POST", "/auth/login", `{"username":"alice","password":"Wr0ngPass!"}`, "")
+		s.do("GET", "/does-not-exist", "", "")
+		s.do("GET", "/user/profile", "", "not.a.jwt")
+
+		checks := []struct {
+			name      string
+			got, want float64
+		}{
+			{"requests by route template", testutil.ToFloat64(logins), before + 1},
+			{"successful logins", testutil.ToFloat64(metrics.Logins.WithLabelValues("success", "")), successes + 1},
+			{"failed logins by reason", testutil.ToFloat64(metrics.Logins.WithLabelValues("failure", "invalid_password")), wrongPassword + 1},
+			{"unmatched routes", testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("GET", "unmatched", "404")), unmatched + 1},
+			{"token failures by error", testutil.ToFloat64(metrics.TokenValidationFailures.WithLabelValues("token_malformed")), malformed + 1},
+		}
+		for _, c := range checks {
+			if c.got != c.want {
+				t.Errorf("%s: expected %v, got %v", c.name, c.want, c.got)
+			}
+		}
+
+		rec := httptest.NewRecorder()
+		metricsRouter.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		body := rec.Body.String()
+		for _, name := range []string{
+			`azyqs_http_request_duration_seconds_bucket{method="POST",route="/auth/login",status="200"`,
+			`azyqs_auth_registrations_total{result="success"}`,
+			`azyqs_password_hash_duration_seconds_count{operation="hash"}`,
+		} {
+			if !strings.Contains(body, name) {
+				t.Errorf("expected /metrics to expose %s", name)
+			}
+		}
+	})
+}
+
+func TestLocalization(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		tests := []struct {
+			name           string
+			method, path   string
+			body           string
+			acceptLanguage string
+			wantLanguage   string
+			wantMessage    string
+			wantLocalized  string
+		}{
+			{"indonesian error", "POST", "/auth/login", `{"username":"nobody","password":"Passw0rd!"}`, "id-ID,id;q=0.9", "id", "unauthorized", "Nama pengguna atau kata sandi salah."},
+			{"english by default", "POST", "/auth/login", `{"username":"nobody","password":"Passw0rd!"}`, "", "en", "unauthorized", "Incorrect username or password."},
+			{"unsupported language", "GET", "/does-not-exist", "", "fr-FR", "en", "route_not_found", "This endpoint does not exist."},
+			{"middleware error", "GET", "/user/profile", "", "id", "id", "token_not_found", "Token akses diperlukan."},
+			{"success", "POST", "/auth/register", `{"username":"alice","name":"Alice","email":"alice@example.com","password":"Passw0rd!"}`, "id", "id", "registration_successful", "Pendaftaran berhasil."},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+				if tt.acceptLanguage != "" {
+					req.Header.Set("Accept-Language", tt.acceptLanguage)
+				}
+				rec := httptest.NewRecorder()
+				s.router.ServeHTTP(rec, req)
+
+				var resp apiResponse
+				if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("invalid JSON response %q: %v", rec.Body.String(), err)
+				}
+				if resp.Message != tt.wantMessage || resp.LocalizedMessage != tt.wantLocalized {
+					t.Fatalf("expected %q %q, got %q %q", tt.wantMessage, tt.wantLocalized, resp.Message, resp.LocalizedMessage)
+				}
+				if got := rec.Header().Get("Content-Language"); got != tt.wantLanguage {
+					t.Fatalf("expected Content-Language %q, got %q", tt.wantLanguage, got)
+				}
+			})
+		}
+	})
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		resp := s.do("GET", "/auth/password-policy", "", "")
+		expect(t, resp, http.StatusOK, "password_policy_found")
+
+		var policy validators.PasswordPolicy
+		if err := json.Unmarshal(resp.Data, &policy); err != nil {
+			t.Fatalf("invalid policy: %v", err)
+		}
+		if policy != validators.Policy {
+			t.Fatalf("expected the active policy %+v, got %+v", validators.Policy, policy)
+		}
+	})
+}
+
+func TestBreachedPasswords(t *testing.T) {
+	filter, err := breach.Build(strings.NewReader("Leaked#Passw0rd\n"), breach.FormatPlain, 1, 0.001)
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	validators.Breached = filter
+	defer func() { validators.Breached = nil }()
+
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		resp := s.do("POST", "/auth/register", `{"username":"bob","name":"Bob","email":"bob@example.com","password":"Leaked#Passw0rd"}`, "")
+		expect(t, resp, http.StatusBadRequest, "validation_failed")
+		expectErrors(t, resp, map[string][]string{"password": {"password_compromised"}})
+
+		s.register("alice", "alice@example.com")
+		token := s.login("alice", testPassword)
+		resp = s.do("PUT", "/user/change-password", `{"old_password":"Passw0rd!","new_password":"Leaked#Passw0rd","confirm_new_password":"Leaked#Passw0rd"}`, token)
+		expect(t, resp, http.StatusBadRequest, "validation_failed")
+		expectErrors(t, resp, map[string][]string{"new_password": {"password_compromised"}})
+	})
+}
+
+func TestPasswordReuse(t *testing.T) {
+	defer func(history int) { validators.Policy.History = history }(validators.Policy.History)
+	validators.Policy.History = 3
+
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		s.register("alice", "alice@example.com")
+		token := s.login("alice", testPassword)
+
+		change := func(oldPassword, newPassword string) apiResponse {
+			body := `{"old_password":"` + oldPassword + `","new_password":"` + newPassword + `","confirm_new_password":"` + newPassword + `"}`
+			return s.do("PUT", "/user/change-password", body, token)
+		}
+		expect(t, change("Passw0rd!", "Passw0rd!"), http.StatusBadRequest, "password_reused")
+		expect(t, change("Passw0rd!", "Sec0nd#Pass"), http.StatusOK, "password_changed")
+		expect(t, change("Sec0nd#Pass", "Passw0rd!"), http.StatusBadRequest, "password_reused")
+		expect(t, change("Sec0nd#Pass", "Th1rd#Pass"), http.StatusOK, "password_changed")
+		expect(t, change("Th1rd#Pass", "Passw0rd!"), http.StatusBadRequest, "password_reused")
+		expect(t, change("Th1rd#Pass", "F0urth#Pass"), http.StatusOK, "password_changed")
+
+		// Only the last three passwords are remembered, so the first may come back
+		expect(t, change("F0urth#Pass", "Passw0rd!"), http.StatusOK, "password_changed")
+		s.login("alice", testPassword)
+	})
+}
+
+func TestForcedPasswordChange(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		s.register("alice", "alice@example.com")
+		alice, _ := s.handler.Users.GetUserByUsername(context.Background(), "alice")
+		token := s.login("alice", testPassword)
+
+		if err := s.handler.Users.ExpirePassword(context.Background(), alice.ID); err != nil {
+			t.Fatalf("ExpirePassword failed: %v", err)
+		}
+		expect(t, s.do("GET", "/user/profile", "", token), http.StatusForbidden, "token_revoked")
+
+		restricted := s.loginToChangePassword("alice", testPassword)
+		expect(t, s.do("GET", "/user/profile", "", restricted), http.StatusForbidden, "password_change_required")
+		expect(t, s.do("GET", "/admin/webhooks", "", restricted), http.StatusForbidden, "password_change_required")
+		expect(t, s.do("PUT", "/user/change-password", `{"old_password":"Passw0rd!","new_password":"N3wPassw0rd!","confirm_new_password":"N3wPassw0rd!"}`, restricted), http.StatusOK, "password_changed")
+		expect(t, s.do("GET", "/user/profile", "", s.login("alice", "N3wPassw0rd!")), http.StatusOK, "profile_found")
+
+		// A temporary password set by an admin must be replaced too
+		if err := s.handler.Users.SetUserPassword(context.Background(), alice.ID, "Temp0rary#Pass", true); err != nil {
+			t.Fatalf("SetUserPassword failed: %v", err)
+		}
+		s.loginToChangePassword("alice", "Temp0rary#Pass")
+	})
+}
+
+func TestPasswordExpiry(t *testing.T) {
+	defer func(maxAge int) { validators.Policy.MaxAgeDays = maxAge }(validators.Policy.MaxAgeDays)
+	validators.Policy.MaxAgeDays = 90
+
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		s.register("alice", "alice@example.com")
+		s.login("alice", testPassword)
+
+		user, err := s.store.Users().FindByUsername("alice")
+		if err != nil {
+			t.Fatalf("failed to find alice: %v", err)
+		}
+		user.PasswordChangedAt = time.Now().AddDate(0, 0, -91)
+		if err := s.store.Users().Update(user); err != nil {
+			t.Fatalf("failed to age the password: %v", err)
+		}
+
+		restricted := s.loginToChangePassword("alice", testPassword)
+		expect(t, s.do("PUT", "/user/change-password", `{"old_password":"Passw0rd!","new_password":"N3wPassw0rd!","confirm_new_password":"N3wPassw0rd!"}`, restricted), http.StatusOK, "password_changed")
+		s.login("alice", "N3wPassw0rd!")
+	})
+}
+
+func TestPasswordRehashOnLogin(t *testing.T) {
+	defer func(previous utils.PasswordHasher) { utils.Hasher = previous }(utils.Hasher)
+
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		utils.Hasher = utils.BcryptHasher{Cost: bcrypt.MinCost}
+		s.register("alice", "alice@example.com")
+
+		utils.Hasher = utils.Argon2idHasher{Memory: 64, Iterations: 1, Parallelism: 1}
+		expect(t, s.do("POST", "/auth/login", `{"username":"alice","password":"Wr0ngPass!"}`, ""), http.StatusUnauthorized, "unauthorized")
+		if user, _ := s.store.Users().FindByUsername("alice"); !strings.HasPrefix(user.Password, "$2") {
+			t.Fatalf("expected a failed login to keep the bcrypt hash, got %q", user.Password)
+		}
+
+		s.login("alice", testPassword)
+		user, _ := s.store.Users().FindByUsername("alice")
+		if !strings.HasPrefix(user.Password, "$argon2id$v=19$m=64,t=1,p=1$") {
+			t.Fatalf("expected the hash to be upgraded to argon2id, got %q", user.Password)
+		}
+		s.login("alice", testPassword)
+	})
+}
+
+func TestHashingSaturation(t *testing.T) {
+	defer func(previous *utils.HashPool) { utils.Hashing = previous }(utils.Hashing)
+
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		s.register("alice", "alice@example.com")
+
+		// Keep the only worker busy; without a queue every other hash is turned away
+		utils.Hashing = utils.NewHashPool(1, 0)
+		started, release, finished := make(chan struct{}), make(chan struct{}), make(chan error)
+		go func() {
+			finished <- utils.Hashing.Do(context.Background(), func() {
+				close(started)
+				<-release
+			})
+		}()
+		<-started
+
+		req := httptest.NewRequest("POST", "/auth/login", strings.NewReader(`{"username":"alice","password":"Passw0rd!"}`))
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+			t.Fatalf("expected 503 with Retry-After, got %d %q", rec.Code, rec.Header().Get("Retry-After"))
+		}
+		var resp apiResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Message != "service_busy" {
+			t.Fatalf("expected service_busy, got %s", rec.Body.String())
+		}
+
+		close(release)
+		if err := <-finished; err != nil {
+			t.Fatalf("blocking job failed: %v", err)
+		}
+		s.login("alice", testPassword)
+	})
+}
@@ -3,19 +3,25 @@ package routes
 import (
 	"azyqs-auth-systems/controllers"
 	"azyqs-auth-systems/middlewares"
+	"azyqs-auth-systems/utils"
 	"net/http"
 
 	"github.com/gorilla/mux"
 )
 
 // RegisterUserRoutes defines routes for user operations
-func RegisterUserRoutes(router *mux.Router) {
+func RegisterUserRoutes(router *mux.Router, h *controllers.Handler) {
 	protected := router.PathPrefix("/user").Subrouter()
-	protected.Use(middlewares.JwtAuthentication)
+	authenticated := middlewares.JwtAuthentication(h.Users)
+	// Users who must change their password get a token that only works here
+	passwordChange := middlewares.JwtAuthentication(h.Users, utils.ScopePasswordChange)
+	// Sensitive changes without a password in the body need a token from /auth/reauthenticate
+	recentAuth := middlewares.RequireRecentAuth(utils.RecentAuthMaxAge)
 
-	protected.HandleFunc("/profile", controllers.ViewProfile).Methods("GET")
-	protected.HandleFunc("/profile", controllers.EditProfile).Methods("PUT")
-	protected.HandleFunc("/profile", controllers.DeleteProfile).Methods("DELETE")
-	protected.HandleFunc("/change-password", controllers.ChangePassword).Methods("PUT")
+	protected.Handle("/profile", authenticated(http.HandlerFunc(h.ViewProfile))).Methods("GET")
+	protected.Handle("/profile", authenticated(recentAuth(http.HandlerFunc(h.EditProfile)))).Methods("PUT")
+	protected.Handle("/profile", authenticated(http.HandlerFunc(h.DeleteProfile))).Methods("DELETE")
+	protected.Handle("/email", authenticated(http.HandlerFunc(h.ChangeEmail))).Methods("PUT")
+	protected.Handle("/change-password", passwordChange(http.HandlerFunc(h.ChangePassword))).Methods("PUT")
 	protected.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowedHandler)
 }
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"azyqs-auth-systems/config"
+	"azyqs-auth-systems/logging"
+	"azyqs-auth-systems/mailer"
+	"azyqs-auth-systems/metrics"
+	"azyqs-auth-systems/middlewares"
+	"azyqs-auth-systems/migrations"
+	"azyqs-auth-systems/routes"
+	"azyqs-auth-systems/services"
+	"azyqs-auth-systems/tracing"
+	"azyqs-auth-systems/utils"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+func isPortAvailable(port string) bool {
+	ln, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return false
+	}
+	_ = ln.Close()
+	return true
+}
+
+// runServe handles the "serve" subcommand
+func runServe(args []string) {
+	// Resolve configuration from defaults, file, environment and flags
+	flags, loader := commandFlags("serve")
+	flags.Parse(args)
+	cfg := loadConfig(loader)
+
+	// Everything from here on logs structured records
+	slog.SetDefault(slog.New(logging.NewHandler(os.Stdout, cfg.Logging)))
+	slog.Info("starting server")
+	go reloadSecretsOnHangup(loader, cfg)
+
+	if err := loadBreachedPasswords(cfg); err != nil {
+		fatal("failed to initialize the password policy", "error", err)
+	}
+
+	// Bound concurrent password hashing so bursts of logins cannot take every CPU
+	utils.Hashing = utils.NewHashPool(cfg.Auth.HashWorkers, cfg.Auth.HashQueue)
+
+	// Check if the port is already in use
+	if !isPortAvailable(cfg.Server.Port) {
+		fatal("port is already in use, choose a different port", "port", cfg.Server.Port)
+	}
+
+	// Install the tracer provider before anything creates spans
+	slog.Info("initializing tracing")
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal("failed to initialize tracing", "error", err)
+	}
+
+	// Initialize database connection
+	slog.Info("initializing database connection")
+	db := config.InitDB(cfg.Database)
+	if err := tracing.InstrumentGORM(db); err != nil {
+		fatal("failed to instrument database queries", "error", err)
+	}
+
+	// Apply pending schema migrations
+	slog.Info("applying database migrations")
+	migrator, err := migrations.New(db)
+	if err != nil {
+		fatal("failed to load migrations", "error", err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		fatal("failed to apply migrations", "error", err)
+	}
+
+	// Outgoing mail is sent from a background queue
+	mail := mailer.NewQueue(mailer.New(cfg.Mail), cfg.Mail.QueueSize)
+
+	// Initialize repositories and services
+	slog.Info("initializing services")
+	handler := newHandler(db, mail)
+	handler.Health = services.NewHealthService(readinessCheckTimeout, readinessChecks(db, migrator, mail)...)
+
+	// Start background webhook delivery
+	slog.Info("starting webhook dispatcher")
+	workers, stopWorkers := context.WithCancel(context.Background())
+	dispatcherDone := make(chan struct{})
+	go func() {
+		defer close(dispatcherDone)
+		handler.Webhooks.RunWebhookDispatcher(workers, cfg.Webhooks.DispatchInterval)
+	}()
+
+	// Initialize router
+	slog.Info("initializing router")
+	router := mux.NewRouter()
+	routes.RegisterRoutes(router, handler)
+
+	// CORS and rate limiting wrap the router so they also see preflight and unmatched requests.
+	// Request IDs are assigned inside the server span so request logs carry the trace ID.
+	var root http.Handler = router
+	root = middlewares.RateLimit(cfg.RateLimit)(root)
+	root = middlewares.CORS(cfg.CORS)(root)
+	root = middlewares.RequestID(slog.Default())(root)
+	root = otelhttp.NewHandler(root, "http.server")
+
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%s", cfg.Server.Port),
+		Handler:           root,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+	}
+
+	stop, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	serveErr := make(chan error, 2)
+	go func() {
+		slog.Info("server is up and running", "port", cfg.Server.Port)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	// Metrics are served on a separate listener so they never reach the public API port
+	var metricsServer *http.Server
+	if cfg.Metrics.Enabled {
+		if sqlDB, err := db.DB(); err == nil {
+			metrics.RegisterDBStats(sqlDB)
+		}
+		metricsRouter := mux.NewRouter()
+		routes.RegisterMetricsRoutes(metricsRouter, cfg.Metrics.Path)
+		metricsServer = &http.Server{
+			Addr:              cfg.Metrics.Addr,
+			Handler:           metricsRouter,
+			ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		}
+		go func() {
+			slog.Info("metrics are served", "addr", cfg.Metrics.Addr, "path", cfg.Metrics.Path)
+			serveErr <- metricsServer.ListenAndServe()
+		}()
+	}
+
+	select {
+	case err := <-serveErr:
+		fatal("server stopped", "error", err)
+	case <-stop.Done():
+	}
+
+	// Fail readiness first so the orchestrator stops routing new traffic here
+	handler.Health.SetShuttingDown()
+	if cfg.Server.ShutdownDelay > 0 {
+		slog.Info("shutting down, failing readiness", "delay", cfg.Server.ShutdownDelay)
+		time.Sleep(cfg.Server.ShutdownDelay)
+	}
+
+	// Drain in-flight requests first, then let the workers finish, within one deadline
+	slog.Info("shutting down", "timeout", cfg.Server.ShutdownTimeout)
+	ctx, cancelShutdown := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancelShutdown()
+
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("failed to drain connections", "error", err)
+	}
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(ctx); err != nil {
+			slog.Error("failed to stop the metrics listener", "error", err)
+		}
+	}
+
+	stopWorkers()
+	select {
+	case <-dispatcherDone:
+	case <-ctx.Done():
+		slog.Error("webhook dispatcher did not stop in time")
+	}
+
+	if err := mail.Close(ctx); err != nil {
+		slog.Error("failed to flush the mail queue", "error", err)
+	}
+
+	if sqlDB, err := db.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			slog.Error("failed to close the database pool", "error", err)
+		}
+	}
+
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("failed to flush traces", "error", err)
+	}
+	slog.Info("server stopped")
+}
+
+// fatal logs an error record and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	serviceErrors "azyqs-auth-systems/errors"
+	"azyqs-auth-systems/logging"
+	"azyqs-auth-systems/models"
+	"azyqs-auth-systems/repositories"
+	"azyqs-auth-systems/tracing"
+	"azyqs-auth-systems/utils"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Audit actions recorded by the services layer
+const (
+	AuditUserRegistered      = "user.registered"
+	AuditUserLogin           = "user.login"
+	AuditUserLoginFailed     = "user.login_failed"
+	AuditUserReauthenticated = "user.reauthenticated"
+	AuditUserReauthFailed    = "user.reauthentication_failed"
+	AuditUserUpdated         = "user.updated"
+	AuditUserDeleted         = "user.deleted"
+	AuditUserPasswordChanged = "user.password_changed"
+	AuditUserPasswordReset   = "user.password_reset"
+	AuditUserPasswordExpired = "user.password_expired"
+	AuditUserDisabled        = "user.disabled"
+	AuditUserRoleGranted     = "user.role_granted"
+	AuditUserSessionsRevoked = "user.sessions_revoked"
+	AuditUserEmailPending    = "user.email_change_requested"
+	AuditUserEmailChanged    = "user.email_changed"
+	AuditUserEmailCancelled  = "user.email_change_cancelled"
+	AuditUserEmailRestored   = "user.email_restored"
+)
+
+// auditCheckpointInterval is the number of events between signed checkpoints
+const auditCheckpointInterval = 100
+
+// auditVerifyBatchSize is the number of events loaded per page during verification
+const auditVerifyBatchSize = 500
+
+// AuditVerification describes the result of walking the audit chain
+type AuditVerification struct {
+	Valid              bool    `json:"valid"`
+	CheckedEvents      int     `json:"checked_events"`
+	CheckedCheckpoints int     `json:"checked_checkpoints"`
+	BrokenAt           *uint64 `json:"broken_at,omitempty"`
+	Reason             string  `json:"reason,omitempty"`
+}
+
+// AuditService appends to and verifies the tamper-evident audit chain
+type AuditService struct {
+	store repositories.Store
+	keys  *utils.Keyring
+}
+
+// NewAuditService creates an AuditService that signs checkpoints with the active key of keys
+func NewAuditService(store repositories.Store, keys *utils.Keyring) *AuditService {
+	return &AuditService{store: store, keys: keys}
+}
+
+// RecordAuditEvent appends an event to the audit chain
+func (s *AuditService) RecordAuditEvent(ctx context.Context, action string, userID *uuid.UUID, metadata map[string]string) error {
+	ctx, span := tracing.Start(ctx, "AuditService.RecordAuditEvent")
+	defer span.End()
+
+	encoded := ""
+	if len(metadata) > 0 {
+		raw, err := json.Marshal(metadata)
+		if err != nil {
+			return err
+		}
+		encoded = string(raw)
+	}
+
+	return s.store.WithContext(ctx).Transaction(func(tx repositories.Store) error {
+		audit := tx.Audit()
+		if err := audit.LockChain(); err != nil {
+			return err
+		}
+
+		prevHash := ""
+		sequence := uint64(1)
+		last, err := audit.LastEvent()
+		switch {
+		case err == nil:
+			prevHash = last.Hash
+			sequence = last.Sequence + 1
+		case !errors.Is(err, serviceErrors.ErrRecordNotFound):
+			return err
+		}
+
+		event := models.AuditEvent{
+			Sequence:  sequence,
+			Action:    action,
+			UserID:    userID,
+			Metadata:  encoded,
+			PrevHash:  prevHash,
+			CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+		}
+		event.Hash = hashAuditEvent(&event)
+
+		if err := audit.CreateEvent(&event); err != nil {
+			return err
+		}
+
+		if sequence%auditCheckpointInterval == 0 {
+			keyID, key := s.keys.Active()
+			return audit.CreateCheckpoint(&models.AuditCheckpoint{
+				Sequence:  event.Sequence,
+				Hash:      event.Hash,
+				Signature: signCheckpoint(key, event.Sequence, event.Hash),
+				KeyID:     keyID,
+			})
+		}
+		return nil
+	})
+}
+
+// record records an audit event and logs failures without interrupting the caller
+func (s *AuditService) record(ctx context.Context, action string, userID *uuid.UUID, metadata map[string]string) {
+	if err := s.RecordAuditEvent(ctx, action, userID, metadata); err != nil {
+		logging.FromContext(ctx).Error("failed to record audit event", "action", action, "error", err)
+	}
+}
+
+// VerifyAuditChain walks the audit chain and reports the first broken link
+func (s *AuditService) VerifyAuditChain(ctx context.Context) (*AuditVerification, error) {
+	ctx, span := tracing.Start(ctx, "AuditService.VerifyAuditChain")
+	defer span.End()
+
+	audit := s.store.WithContext(ctx).Audit()
+	result := &AuditVerification{Valid: true}
+	expectedSequence := uint64(1)
+	prevHash := ""
+
+	for {
+		events, err := audit.ListEvents(expectedSequence-1, auditVerifyBatchSize)
+		if err != nil {
+			return nil, serviceErrors.ErrAuditVerifyFailed
+		}
+		for i := range events {
+			event := &events[i]
+			switch {
+			case event.Sequence != expectedSequence:
+				result.fail(expectedSequence, "sequence_gap")
+			case event.PrevHash != prevHash:
+				result.fail(event.Sequence, "prev_hash_mismatch")
+			case hashAuditEvent(event) != event.Hash:
+				result.fail(event.Sequence, "hash_mismatch")
+			}
+			if !result.Valid {
+				return result, nil
+			}
+			result.CheckedEvents++
+			prevHash = event.Hash
+			expectedSequence++
+		}
+		if len(events) < auditVerifyBatchSize {
+			break
+		}
+	}
+
+	checkpoints, err := audit.ListCheckpoints()
+	if err != nil {
+		return nil, serviceErrors.ErrAuditVerifyFailed
+	}
+	for _, checkpoint := range checkpoints {
+		// Checkpoints outlive tokens, so keys retired from token verification still count
+		key, ok := s.keys.LookupRetained(checkpoint.KeyID)
+		if !ok {
+			result.fail(checkpoint.Sequence, "checkpoint_key_unknown")
+			return result, nil
+		}
+		if !hmac.Equal([]byte(checkpoint.Signature), []byte(signCheckpoint(key, checkpoint.Sequence, checkpoint.Hash))) {
+			result.fail(checkpoint.Sequence, "checkpoint_signature_invalid")
+			return result, nil
+		}
+
+		event, err := audit.FindEvent(checkpoint.Sequence)
+		if err != nil {
+			result.fail(checkpoint.Sequence, "checkpoint_event_missing")
+			return result, nil
+		}
+		if event.Hash != checkpoint.Hash {
+			result.fail(checkpoint.Sequence, "checkpoint_hash_mismatch")
+			return result, nil
+		}
+		result.CheckedCheckpoints++
+	}
+
+	return result, nil
+}
+
+func (v *AuditVerification) fail(sequence uint64, reason string) {
+	v.Valid = false
+	v.BrokenAt = &sequence
+	v.Reason = reason
+}
+
+// hashAuditEvent computes the chained SHA-256 hash of an audit event
+func hashAuditEvent(event *models.AuditEvent) string {
+	userID := ""
+	if event.UserID != nil {
+		userID = event.UserID.String()
+	}
+	payload := fmt.Sprintf("%d|%s|%s|%s|%s|%s",
+		event.Sequence,
+		event.PrevHash,
+		event.Action,
+		userID,
+		event.Metadata,
+		event.CreatedAt.UTC().Format(time.RFC3339Nano),
+	)
+	sum := sha256.Sum256([]byte(payload))
+	return hex.EncodeToString(sum[:])
+}
+
+// signCheckpoint signs a checkpoint with a token signing key
+func signCheckpoint(key []byte, sequence uint64, hash string) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%d|%s", sequence, hash)
+	return hex.EncodeToString(mac.Sum(nil))
+}
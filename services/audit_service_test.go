@@ -0,0 +1,132 @@
+package services
+
+import (
+	"azyqs-auth-systems/repositories"
+	"azyqs-auth-systems/utils"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestAuditChainDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(db *gorm.DB)
+		broken uint64
+		reason string
+	}{
+		{
+			name: "edited action",
+			tamper: func(db *gorm.DB) {
+				db.Exec("UPDATE audit_events SET action = ? WHERE sequence = ?", "user.updated", 2)
+			},
+			broken: 2,
+			reason: "hash_mismatch",
+		},
+		{
+			name: "deleted event",
+			tamper: func(db *gorm.DB) {
+				db.Exec("DELETE FROM audit_events WHERE sequence = ?", 3)
+			},
+			broken: 3,
+			reason: "sequence_gap",
+		},
+		{
+			name: "rewritten link",
+			tamper: func(db *gorm.DB) {
+				db.Exec("UPDATE audit_events SET prev_hash = ? WHERE sequence = ?", "forged", 4)
+			},
+			broken: 4,
+			reason: "prev_hash_mismatch",
+		},
+		{
+			name: "forged checkpoint",
+			tamper: func(db *gorm.DB) {
+				db.Exec("UPDATE audit_checkpoints SET signature = ? WHERE sequence = ?", "forged", auditCheckpointInterval)
+			},
+			broken: auditCheckpointInterval,
+			reason: "checkpoint_signature_invalid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newSQLiteDB(t)
+			_, _, audit, _ := newTestServices(repositories.NewGormStore(db))
+
+			for i := 0; i < auditCheckpointInterval; i++ {
+				if err := audit.RecordAuditEvent(context.Background(), AuditUserLogin, nil, map[string]string{"n": "x"}); err != nil {
+					t.Fatalf("RecordAuditEvent failed: %v", err)
+				}
+			}
+
+			result, err := audit.VerifyAuditChain(context.Background())
+			if err != nil || !result.Valid || result.CheckedEvents != auditCheckpointInterval || result.CheckedCheckpoints != 1 {
+				t.Fatalf("expected an intact chain with one checkpoint, got %+v (%v)", result, err)
+			}
+
+			tt.tamper(db)
+
+			result, err = audit.VerifyAuditChain(context.Background())
+			if err != nil {
+				t.Fatalf("VerifyAuditChain failed: %v", err)
+			}
+			if result.Valid || result.BrokenAt == nil || *result.BrokenAt != tt.broken || result.Reason != tt.reason {
+				t.Fatalf("expected break at %d (%s), got %+v", tt.broken, tt.reason, result)
+			}
+		})
+	}
+}
+
+func TestAuditChainRejectsForeignSigningKey(t *testing.T) {
+	store := repositories.NewMemoryStore()
+	_, _, audit, _ := newTestServices(store)
+	for i := 0; i < auditCheckpointInterval; i++ {
+		audit.record(context.Background(), AuditUserLogin, nil, nil)
+	}
+
+	result, err := NewAuditService(store, utils.NewKeyring([]byte("another_key"))).VerifyAuditChain(context.Background())
+	if err != nil {
+		t.Fatalf("VerifyAuditChain failed: %v", err)
+	}
+	if result.Valid || result.Reason != "checkpoint_signature_invalid" {
+		t.Fatalf("expected checkpoint signature failure, got %+v", result)
+	}
+
+	events, _ := store.Audit().ListEvents(0, 1)
+	if len(events) != 1 || events[0].PrevHash != "" {
+		t.Fatalf("first event must have an empty previous hash, got %+v", events)
+	}
+}
+
+func TestAuditChainSurvivesKeyRotation(t *testing.T) {
+	store := repositories.NewMemoryStore()
+	legacy := []byte("legacy_signing_key")
+	keys := utils.NewKeyring(legacy)
+	audit := NewAuditService(store, keys)
+	path := filepath.Join(t.TempDir(), "keys.json")
+
+	// One checkpoint with the legacy key, then one per key until the first is pruned
+	for rotation := 0; rotation <= utils.DefaultKeysKept+1; rotation++ {
+		for i := 0; i < auditCheckpointInterval; i++ {
+			audit.record(context.Background(), AuditUserLogin, nil, nil)
+		}
+		if _, err := utils.RotateKeyFile(path, utils.DefaultKeysKept, legacy); err != nil {
+			t.Fatalf("RotateKeyFile failed: %v", err)
+		}
+		if err := keys.Load(path); err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+	}
+
+	// Verification still succeeds once JWT_SECRET is removed
+	if err := keys.Reload(nil, path); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	result, err := audit.VerifyAuditChain(context.Background())
+	if err != nil || !result.Valid || result.CheckedCheckpoints != utils.DefaultKeysKept+2 {
+		t.Fatalf("expected every checkpoint to verify after rotation, got %+v (%v)", result, err)
+	}
+}
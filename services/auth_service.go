@@ -1,61 +1,216 @@
 package services
 
 import (
-	"azyqs-auth-systems/config"
 	serviceErrors "azyqs-auth-systems/errors"
+	"azyqs-auth-systems/logging"
+	"azyqs-auth-systems/metrics"
 	"azyqs-auth-systems/models"
+	"azyqs-auth-systems/repositories"
+	"azyqs-auth-systems/tracing"
 	"azyqs-auth-systems/utils"
+	"context"
 	"errors"
 	"strings"
+	"time"
 
-	"gorm.io/gorm"
+	"github.com/google/uuid"
 )
 
+// Login is the outcome of a successful login. When MustChangePassword is set the token
+// only allows changing the password.
+type Login struct {
+	Token              string `json:"token"`
+	MustChangePassword bool   `json:"must_change_password"`
+}
+
+// AuthService handles registration and login
+type AuthService struct {
+	store repositories.Store
+	audit *AuditService
+}
+
+// NewAuthService creates an AuthService backed by store
+func NewAuthService(store repositories.Store, audit *AuditService) *AuthService {
+	return &AuthService{store: store, audit: audit}
+}
+
 // RegisterUser registers a new user
-func RegisterUser(username, name, email, password string) error {
-	var count int64
-	config.DB.Model(&models.User{}).Where("username = ? OR email = ?", username, email).Count(&count)
-	if count > 0 {
+func (s *AuthService) RegisterUser(ctx context.Context, username, name, email, password string) error {
+	ctx, span := tracing.Start(ctx, "AuthService.RegisterUser")
+	defer span.End()
+
+	err := s.registerUser(ctx, username, name, email, password)
+	switch {
+	case err == nil:
+		metrics.Registrations.WithLabelValues("success").Inc()
+	case errors.Is(err, serviceErrors.ErrDuplicateRecord):
+		metrics.Registrations.WithLabelValues(err.Error()).Inc()
+	default:
+		metrics.Registrations.WithLabelValues("error").Inc()
+	}
+	return err
+}
+
+func (s *AuthService) registerUser(ctx context.Context, username, name, email, password string) error {
+	email = normalizeEmail(email)
+	exists, err := s.store.WithContext(ctx).Users().ExistsByUsernameOrEmail(username, email)
+	if err != nil {
+		return err
+	}
+	if exists {
 		return serviceErrors.ErrDuplicateRecord
 	}
 
-	hashedPassword, err := utils.HashPassword(password)
+	hashedPassword, err := hashPassword(ctx, password)
 	if err != nil {
-		return serviceErrors.ErrPasswordHash
+		return err
 	}
 
 	user := models.User{
-		Username: username,
-		Name:     name,
-		Email:    email,
-		Password: hashedPassword,
+		Username:          username,
+		Name:              name,
+		Email:             email,
+		Password:          hashedPassword,
+		PasswordChangedAt: time.Now(),
 	}
 
-	if err := config.DB.Create(&user).Error; err != nil {
-		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
-			return serviceErrors.ErrDuplicateRecord
+	err = s.store.WithContext(ctx).Transaction(func(tx repositories.Store) error {
+		if err := tx.Users().Create(&user); err != nil {
+			return err
 		}
+		return enqueueOutboxEvent(tx, EventUserRegistered, newUserEventData(&user))
+	})
+	if err != nil {
 		return err
 	}
+
+	s.audit.record(ctx, AuditUserRegistered, &user.ID, nil)
 	return nil
 }
 
-// LoginUser authenticates a user and returns a JWT token
-func LoginUser(username, password string) (string, error) {
-	var user models.User
-	if err := config.DB.Where("username = ?", username).First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return "", serviceErrors.ErrUserNotFound
+// LoginUser authenticates a user by username or email and returns a JWT token, restricted to
+// changing the password when an admin required it or the password is older than the policy allows
+func (s *AuthService) LoginUser(ctx context.Context, identifier, password string) (*Login, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.LoginUser")
+	defer span.End()
+
+	login, err := s.loginUser(ctx, identifier, password)
+	switch {
+	case err == nil:
+		metrics.Logins.WithLabelValues("success", "").Inc()
+	case errors.Is(err, serviceErrors.ErrUserNotFound),
+		errors.Is(err, serviceErrors.ErrInvalidPassword),
+		errors.Is(err, serviceErrors.ErrUserDisabled),
+		errors.Is(err, serviceErrors.ErrServiceBusy):
+		metrics.Logins.WithLabelValues("failure", err.Error()).Inc()
+	default:
+		metrics.Logins.WithLabelValues("failure", "error").Inc()
+	}
+	return login, err
+}
+
+func (s *AuthService) loginUser(ctx context.Context, identifier, password string) (*Login, error) {
+	// Usernames cannot contain "@", so anything that does is an email address
+	users := s.store.WithContext(ctx).Users()
+	find := users.FindByUsername
+	if strings.Contains(identifier, "@") {
+		find = users.FindByEmail
+	}
+	user, err := find(identifier)
+	if err != nil {
+		if errors.Is(err, serviceErrors.ErrRecordNotFound) {
+			return nil, serviceErrors.ErrUserNotFound
 		}
-		return "", err
+		return nil, err
+	}
+	match, err := checkPassword(ctx, password, user.Password)
+	if err != nil {
+		return nil, err
 	}
-	if !utils.CheckPasswordHash(password, user.Password) {
-		return "", serviceErrors.ErrInvalidPassword
+	if !match {
+		s.audit.record(ctx, AuditUserLoginFailed, &user.ID, map[string]string{"reason": serviceErrors.ErrInvalidPassword.Error()})
+		return nil, serviceErrors.ErrInvalidPassword
 	}
 
-	token, err := utils.GenerateJWT(user.ID)
+	if user.Disabled {
+		s.audit.record(ctx, AuditUserLoginFailed, &user.ID, map[string]string{"reason": serviceErrors.ErrUserDisabled.Error()})
+		return nil, serviceErrors.ErrUserDisabled
+	}
+
+	if utils.NeedsRehash(user.Password) {
+		s.rehashPassword(ctx, user.ID, user.Password, password)
+	}
+
+	if user.MustChangePassword || passwordExpired(user, time.Now()) {
+		token, err := utils.GeneratePasswordChangeJWT(user.ID, user.TokenVersion)
+		if err != nil {
+			return nil, err
+		}
+		s.audit.record(ctx, AuditUserLogin, &user.ID, map[string]string{"scope": utils.ScopePasswordChange})
+		return &Login{Token: token, MustChangePassword: true}, nil
+	}
+
+	token, err := utils.GenerateJWT(user.ID, user.TokenVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.record(ctx, AuditUserLogin, &user.ID, nil)
+	return &Login{Token: token}, nil
+}
+
+// Reauthenticate checks the password of an already logged-in user and returns a short-lived
+// token that counts as a recent authentication
+func (s *AuthService) Reauthenticate(ctx context.Context, userID uuid.UUID, password string) (*Login, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.Reauthenticate")
+	defer span.End()
+
+	user, err := s.store.WithContext(ctx).Users().FindByID(userID)
+	if err != nil {
+		if errors.Is(err, serviceErrors.ErrRecordNotFound) {
+			return nil, serviceErrors.ErrUserNotFound
+		}
+		return nil, err
+	}
+	match, err := checkPassword(ctx, password, user.Password)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return token, nil
-}
\ No newline at end of file
+	if !match {
+		s.audit.record(ctx, AuditUserReauthFailed, &user.ID, map[string]string{"reason": serviceErrors.ErrInvalidPassword.Error()})
+		return nil, serviceErrors.ErrInvalidPassword
+	}
+
+	// A password that has to be changed first cannot unlock sensitive routes either
+	if user.MustChangePassword || passwordExpired(user, time.Now()) {
+		return nil, serviceErrors.ErrPasswordChangeRequired
+	}
+
+	token, err := utils.GenerateElevatedJWT(user.ID, user.TokenVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.record(ctx, AuditUserReauthenticated, &user.ID, nil)
+	return &Login{Token: token}, nil
+}
+
+// rehashPassword replaces a hash made with an outdated algorithm or parameters while the
+// plaintext is at hand; failures only delay the upgrade to the next login
+func (s *AuthService) rehashPassword(ctx context.Context, userID uuid.UUID, oldHash, password string) {
+	hashedPassword, err := hashPassword(ctx, password)
+	if err == nil {
+		err = s.store.WithContext(ctx).Transaction(func(tx repositories.Store) error {
+			user, err := tx.Users().FindByID(userID)
+			// Leave the hash alone if the password changed since it was checked
+			if err != nil || user.Password != oldHash {
+				return err
+			}
+			user.Password = hashedPassword
+			return tx.Users().Update(user)
+		})
+	}
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed to upgrade password hash", "user_id", userID, "error", err)
+	}
+}
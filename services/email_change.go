@@ -0,0 +1,253 @@
+package services
+
+import (
+	serviceErrors "azyqs-auth-systems/errors"
+	"azyqs-auth-systems/logging"
+	"azyqs-auth-systems/mailer"
+	"azyqs-auth-systems/models"
+	"azyqs-auth-systems/repositories"
+	"azyqs-auth-systems/tracing"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmailChangeTTL is how long a requested email change may be confirmed, and how long the
+// previous address may undo it after that
+var EmailChangeTTL = 24 * time.Hour
+
+// LinkBaseURL is the public URL of the API that links in emails point at. Each link opens
+// GET /auth/email-change/{confirm,cancel}?token= below it, which only hands the token back
+// for the POST that makes the change.
+var LinkBaseURL = "http://localhost:8080"
+
+// RequestEmailChange starts replacing a user's email address once their password checks out.
+// The new address receives a link to confirm the change and the current one a link to cancel it;
+// a later request replaces the pending one, but not a confirmed one the old address may still undo.
+func (s *UserService) RequestEmailChange(ctx context.Context, userID uuid.UUID, newEmail, password string) (*models.EmailChange, error) {
+	ctx, span := tracing.Start(ctx, "UserService.RequestEmailChange")
+	defer span.End()
+
+	users := s.store.WithContext(ctx).Users()
+	user, err := users.FindByID(userID)
+	if err != nil {
+		return nil, serviceErrors.ErrUserNotFound
+	}
+	match, err := checkPassword(ctx, password, user.Password)
+	if err != nil {
+		return nil, err
+	}
+	if !match {
+		return nil, serviceErrors.ErrPasswordMismatch
+	}
+
+	newEmail = normalizeEmail(newEmail)
+	if newEmail == user.Email {
+		return nil, serviceErrors.ErrEmailUnchanged
+	}
+	taken, err := users.EmailTaken(newEmail, userID)
+	if err != nil {
+		return nil, serviceErrors.ErrUserUpdateFailed
+	}
+	if taken {
+		return nil, serviceErrors.ErrEmailTaken
+	}
+	previous, err := users.FindEmailChange(userID)
+	switch {
+	case err == nil:
+		if previous.ConfirmedAt != nil && time.Now().Before(previous.ExpiresAt) {
+			return nil, serviceErrors.ErrEmailChangeLocked
+		}
+	case !errors.Is(err, serviceErrors.ErrRecordNotFound):
+		return nil, serviceErrors.ErrUserUpdateFailed
+	}
+
+	confirmToken, confirmHash, err := newEmailChangeToken()
+	if err != nil {
+		return nil, err
+	}
+	cancelToken, cancelHash, err := newEmailChangeToken()
+	if err != nil {
+		return nil, err
+	}
+	change := &models.EmailChange{
+		UserID:           user.ID,
+		OldEmail:         user.Email,
+		NewEmail:         newEmail,
+		ConfirmTokenHash: confirmHash,
+		CancelTokenHash:  cancelHash,
+		ExpiresAt:        time.Now().Add(EmailChangeTTL),
+	}
+	err = s.store.WithContext(ctx).Transaction(func(tx repositories.Store) error {
+		return tx.Users().SaveEmailChange(change)
+	})
+	if err != nil {
+		return nil, serviceErrors.ErrUserUpdateFailed
+	}
+
+	// The current address hears about the change first so it can never be confirmed unnoticed
+	notice := mailer.Message{
+		To:      []string{user.Email},
+		Subject: "Your email address is being changed",
+		Body: fmt.Sprintf("A change of your email address to %s was requested.\n\n"+
+			"If this was not you, cancel it and change your password. The link undoes the change "+
+			"until %s, even once it is confirmed:\n%s\n",
+			newEmail, change.ExpiresAt.Add(EmailChangeTTL).UTC().Format(time.RFC1123), emailChangeLink("cancel", cancelToken)),
+	}
+	confirmation := mailer.Message{
+		To:      []string{newEmail},
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf("Confirm that this is your new email address by opening this link before %s:\n%s\n",
+			change.ExpiresAt.UTC().Format(time.RFC1123), emailChangeLink("confirm", confirmToken)),
+	}
+	for _, msg := range []mailer.Message{notice, confirmation} {
+		if err := s.mail.Send(ctx, msg); err != nil {
+			logging.FromContext(ctx).Error("failed to send email change message", "user_id", user.ID, "subject", msg.Subject, "error", err)
+			return nil, serviceErrors.ErrServiceBusy
+		}
+	}
+
+	s.audit.record(ctx, AuditUserEmailPending, &user.ID, nil)
+	return change, nil
+}
+
+// ConfirmEmailChange replaces a user's email address with the one the token was sent to. The
+// change is kept for another EmailChangeTTL so its cancel link can still restore the old address.
+func (s *UserService) ConfirmEmailChange(ctx context.Context, token string) error {
+	ctx, span := tracing.Start(ctx, "UserService.ConfirmEmailChange")
+	defer span.End()
+
+	var userID uuid.UUID
+	err := s.store.WithContext(ctx).Transaction(func(tx repositories.Store) error {
+		change, err := tx.Users().FindEmailChangeByConfirmToken(hashEmailChangeToken(token))
+		if err != nil {
+			return err
+		}
+		if change.ConfirmedAt != nil || time.Now().After(change.ExpiresAt) {
+			return serviceErrors.ErrInvalidEmailToken
+		}
+
+		// The address may have been taken since the change was requested
+		taken, err := tx.Users().EmailTaken(change.NewEmail, change.UserID)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return serviceErrors.ErrEmailTaken
+		}
+		user, err := tx.Users().FindByID(change.UserID)
+		if err != nil {
+			return err
+		}
+		user.Email = change.NewEmail
+		if err := tx.Users().Update(user); err != nil {
+			return err
+		}
+		now := time.Now()
+		change.ConfirmedAt = &now
+		change.ExpiresAt = now.Add(EmailChangeTTL)
+		if err := tx.Users().SaveEmailChange(change); err != nil {
+			return err
+		}
+		userID = user.ID
+		return enqueueOutboxEvent(tx, EventUserUpdated, newUserEventData(user))
+	})
+	switch {
+	case errors.Is(err, serviceErrors.ErrRecordNotFound), errors.Is(err, serviceErrors.ErrInvalidEmailToken):
+		return serviceErrors.ErrInvalidEmailToken
+	case errors.Is(err, serviceErrors.ErrEmailTaken), errors.Is(err, serviceErrors.ErrDuplicateRecord):
+		return serviceErrors.ErrEmailTaken
+	case err != nil:
+		return serviceErrors.ErrUserUpdateFailed
+	}
+
+	s.audit.record(ctx, AuditUserEmailChanged, &userID, nil)
+	return nil
+}
+
+// CancelEmailChange discards the email change the cancel token was issued for. A change that
+// was already confirmed is undone: the previous address is restored and every session revoked.
+func (s *UserService) CancelEmailChange(ctx context.Context, token string) error {
+	ctx, span := tracing.Start(ctx, "UserService.CancelEmailChange")
+	defer span.End()
+
+	var change *models.EmailChange
+	err := s.store.WithContext(ctx).Transaction(func(tx repositories.Store) error {
+		var err error
+		change, err = tx.Users().FindEmailChangeByCancelToken(hashEmailChangeToken(token))
+		if err != nil {
+			return err
+		}
+		if time.Now().After(change.ExpiresAt) {
+			return serviceErrors.ErrInvalidEmailToken
+		}
+		if err := tx.Users().DeleteEmailChange(change.UserID); err != nil {
+			return err
+		}
+		if change.ConfirmedAt == nil {
+			return nil
+		}
+
+		// The previous address may have been taken since the change was confirmed
+		taken, err := tx.Users().EmailTaken(change.OldEmail, change.UserID)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return serviceErrors.ErrEmailTaken
+		}
+		user, err := tx.Users().FindByID(change.UserID)
+		if err != nil {
+			return err
+		}
+		user.Email = change.OldEmail
+		user.TokenVersion++
+		if err := tx.Users().Update(user); err != nil {
+			return err
+		}
+		return enqueueOutboxEvent(tx, EventUserUpdated, newUserEventData(user))
+	})
+	switch {
+	case errors.Is(err, serviceErrors.ErrRecordNotFound), errors.Is(err, serviceErrors.ErrInvalidEmailToken):
+		return serviceErrors.ErrInvalidEmailToken
+	case errors.Is(err, serviceErrors.ErrEmailTaken), errors.Is(err, serviceErrors.ErrDuplicateRecord):
+		return serviceErrors.ErrEmailTaken
+	case err != nil:
+		return serviceErrors.ErrUserUpdateFailed
+	}
+
+	if change.ConfirmedAt != nil {
+		s.audit.record(ctx, AuditUserEmailRestored, &change.UserID, nil)
+		return nil
+	}
+	s.audit.record(ctx, AuditUserEmailCancelled, &change.UserID, nil)
+	return nil
+}
+
+// newEmailChangeToken returns a random token for an email link and the hash stored in its place
+func newEmailChangeToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", errors.New("email_change_token_generation_failed")
+	}
+	token = hex.EncodeToString(buf)
+	return token, hashEmailChangeToken(token), nil
+}
+
+func hashEmailChangeToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// emailChangeLink returns the link for action ("confirm" or "cancel") carrying token
+func emailChangeLink(action, token string) string {
+	return strings.TrimSuffix(LinkBaseURL, "/") + "/auth/email-change/" + action + "?token=" + url.QueryEscape(token)
+}
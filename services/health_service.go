@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Health check statuses
+const (
+	HealthOK      = "ok"
+	HealthFailing = "failing"
+)
+
+// HealthCheck is one dependency readiness depends on
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// HealthCheckResult is the outcome of one HealthCheck
+type HealthCheckResult struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// ReadinessReport is the outcome of every readiness check
+type ReadinessReport struct {
+	Ready        bool                `json:"ready"`
+	ShuttingDown bool                `json:"shutting_down"`
+	Checks       []HealthCheckResult `json:"checks"`
+	DurationMS   float64             `json:"duration_ms"`
+}
+
+// HealthService answers liveness and readiness probes
+type HealthService struct {
+	checks       []HealthCheck
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+// NewHealthService returns a HealthService running checks with a per-check timeout
+func NewHealthService(timeout time.Duration, checks ...HealthCheck) *HealthService {
+	return &HealthService{checks: checks, timeout: timeout}
+}
+
+// SetShuttingDown makes readiness fail from now on so traffic drains away before shutdown
+func (s *HealthService) SetShuttingDown() {
+	s.shuttingDown.Store(true)
+}
+
+// CheckReadiness runs every check concurrently; it skips them once shutdown has started
+func (s *HealthService) CheckReadiness(ctx context.Context) ReadinessReport {
+	start := time.Now()
+	if s.shuttingDown.Load() {
+		return ReadinessReport{ShuttingDown: true, Checks: []HealthCheckResult{}}
+	}
+
+	results := make([]HealthCheckResult, len(s.checks))
+	var wg sync.WaitGroup
+	for i, check := range s.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = s.run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	report := ReadinessReport{Ready: true, Checks: results, DurationMS: milliseconds(time.Since(start))}
+	for _, result := range results {
+		if result.Status != HealthOK {
+			report.Ready = false
+		}
+	}
+	return report
+}
+
+// run executes one check, giving up when its timeout ends
+func (s *HealthService) run(ctx context.Context, check HealthCheck) HealthCheckResult {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() { errc <- check.Check(ctx) }()
+
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := HealthCheckResult{Name: check.Name, Status: HealthOK, DurationMS: milliseconds(time.Since(start))}
+	if err != nil {
+		result.Status = HealthFailing
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
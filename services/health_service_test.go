@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckReadiness(t *testing.T) {
+	ok := HealthCheck{Name: "ok", Check: func(ctx context.Context) error { return nil }}
+	broken := HealthCheck{Name: "broken", Check: func(ctx context.Context) error { return errors.New("connection refused") }}
+	slow := HealthCheck{Name: "slow", Check: func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}}
+
+	tests := []struct {
+		name      string
+		checks    []HealthCheck
+		wantReady bool
+		wantError map[string]string
+	}{
+		{"no checks", nil, true, nil},
+		{"all passing", []HealthCheck{ok, ok}, true, nil},
+		{"one failing", []HealthCheck{ok, broken}, false, map[string]string{"broken": "connection refused"}},
+		{"timed out", []HealthCheck{ok, slow}, false, map[string]string{"slow": context.DeadlineExceeded.Error()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := NewHealthService(20*time.Millisecond, tt.checks...).CheckReadiness(context.Background())
+			if report.Ready != tt.wantReady {
+				t.Fatalf("expected ready=%v, got %+v", tt.wantReady, report)
+			}
+			if len(report.Checks) != len(tt.checks) {
+				t.Fatalf("expected %d results, got %d", len(tt.checks), len(report.Checks))
+			}
+			for i, result := range report.Checks {
+				if result.Name != tt.checks[i].Name {
+					t.Errorf("expected results in check order, got %s at %d", result.Name, i)
+				}
+				want, failing := tt.wantError[result.Name]
+				if failing && (result.Status != HealthFailing || result.Error != want) {
+					t.Errorf("expected %s to fail with %q, got %+v", result.Name, want, result)
+				}
+				if !failing && result.Status != HealthOK {
+					t.Errorf("expected %s to pass, got %+v", result.Name, result)
+				}
+			}
+		})
+	}
+}
+
+func TestReadinessFailsWhileShuttingDown(t *testing.T) {
+	called := false
+	health := NewHealthService(time.Second, HealthCheck{Name: "db", Check: func(ctx context.Context) error {
+		called = true
+		return nil
+	}})
+	health.SetShuttingDown()
+
+	report := health.CheckReadiness(context.Background())
+	if report.Ready || !report.ShuttingDown {
+		t.Fatalf("expected a failing shutdown report, got %+v", report)
+	}
+	if called {
+		t.Error("expected checks to be skipped during shutdown")
+	}
+}
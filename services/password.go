@@ -0,0 +1,104 @@
+package services
+
+import (
+	serviceErrors "azyqs-auth-systems/errors"
+	"azyqs-auth-systems/models"
+	"azyqs-auth-systems/repositories"
+	"azyqs-auth-systems/tracing"
+	"azyqs-auth-systems/utils"
+	"azyqs-auth-systems/validators"
+	"context"
+	"errors"
+	"time"
+)
+
+// hashPassword hashes password in its own span so slow hashing shows up in traces
+func hashPassword(ctx context.Context, password string) (string, error) {
+	ctx, span := tracing.Start(ctx, "password.hash")
+	defer span.End()
+
+	var hash string
+	var hashErr error
+	if err := runHashing(ctx, func() { hash, hashErr = utils.HashPassword(password) }); err != nil {
+		return "", err
+	}
+	if hashErr != nil {
+		return "", serviceErrors.ErrPasswordHash
+	}
+	return hash, nil
+}
+
+// checkPassword compares password with hash in its own span
+func checkPassword(ctx context.Context, password, hash string) (bool, error) {
+	ctx, span := tracing.Start(ctx, "password.compare")
+	defer span.End()
+
+	var match bool
+	err := runHashing(ctx, func() { match = utils.CheckPasswordHash(password, hash) })
+	return match, err
+}
+
+// runHashing runs fn on the hashing pool and reports a saturated pool as ErrServiceBusy
+func runHashing(ctx context.Context, fn func()) error {
+	err := utils.Hashing.Do(ctx, fn)
+	if errors.Is(err, utils.ErrHashPoolFull) {
+		return serviceErrors.ErrServiceBusy
+	}
+	return err
+}
+
+// checkPasswordReuse returns ErrPasswordReused when password is the user's current password
+// or one of the previous ones validators.Policy.History remembers
+func checkPasswordReuse(ctx context.Context, users repositories.UserRepository, user *models.User, password string) error {
+	remembered := validators.Policy.History
+	if remembered <= 0 {
+		return nil
+	}
+	match, err := checkPassword(ctx, password, user.Password)
+	if err != nil {
+		return err
+	}
+	if match {
+		return serviceErrors.ErrPasswordReused
+	}
+	if remembered == 1 {
+		return nil
+	}
+
+	history, err := users.ListPasswordHistory(user.ID, remembered-1)
+	if err != nil {
+		return err
+	}
+	for _, entry := range history {
+		match, err := checkPassword(ctx, password, entry.Hash)
+		if err != nil {
+			return err
+		}
+		if match {
+			return serviceErrors.ErrPasswordReused
+		}
+	}
+	return nil
+}
+
+// rememberPassword moves the user's current password hash into their history and prunes
+// entries validators.Policy.History no longer needs; call it before replacing the password
+func rememberPassword(users repositories.UserRepository, user *models.User) error {
+	keep := validators.Policy.History - 1
+	if keep > 0 {
+		err := users.AddPasswordHistory(&models.PasswordHistory{UserID: user.ID, Hash: user.Password})
+		if err != nil {
+			return err
+		}
+	}
+	return users.PrunePasswordHistory(user.ID, max(keep, 0))
+}
+
+// passwordExpired reports whether the user's password is older than validators.Policy.MaxAgeDays
+func passwordExpired(user *models.User, now time.Time) bool {
+	maxAge := validators.Policy.MaxAgeDays
+	if maxAge <= 0 {
+		return false
+	}
+	return now.Sub(user.PasswordChangedAt) > time.Duration(maxAge)*24*time.Hour
+}
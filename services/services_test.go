@@ -0,0 +1,57 @@
+package services
+
+import (
+	"azyqs-auth-systems/mailer"
+	"azyqs-auth-systems/models"
+	"azyqs-auth-systems/repositories"
+	"azyqs-auth-systems/utils"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMain(m *testing.M) {
+	utils.Hasher = utils.BcryptHasher{Cost: bcrypt.MinCost}
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// newSQLiteDB opens a migrated SQLite database in a temporary directory
+func newSQLiteDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	// The schema comes from the models; migrations.TestModelsMatchEmbeddedMigrations keeps
+	// them in line with the SQL migrations production runs
+	err = db.AutoMigrate(
+		&models.User{},
+		&models.PasswordHistory{},
+		&models.EmailChange{},
+		&models.AuditEvent{},
+		&models.AuditCheckpoint{},
+		&models.WebhookEndpoint{},
+		&models.OutboxEvent{},
+		&models.WebhookDelivery{},
+	)
+	if err != nil {
+		t.Fatalf("failed to migrate sqlite: %v", err)
+	}
+	return db
+}
+
+// newTestServices wires the services against store
+func newTestServices(store repositories.Store) (*AuthService, *UserService, *AuditService, *WebhookService) {
+	audit := NewAuditService(store, utils.NewKeyring([]byte("test_signing_key")))
+	return NewAuthService(store, audit), NewUserService(store, audit, &mailer.LogMailer{}), audit, NewWebhookService(store)
+}
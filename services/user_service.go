@@ -1,100 +1,293 @@
 package services
 
 import (
-	"azyqs-auth-systems/config"
 	serviceErrors "azyqs-auth-systems/errors"
+	"azyqs-auth-systems/logging"
+	"azyqs-auth-systems/mailer"
 	"azyqs-auth-systems/models"
-	"azyqs-auth-systems/utils"
+	"azyqs-auth-systems/repositories"
+	"azyqs-auth-systems/tracing"
+	"context"
 	"errors"
-	"log"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
-	"gorm.io/gorm"
 )
 
+// UserService handles operations on a user's own account
+type UserService struct {
+	store repositories.Store
+	audit *AuditService
+	mail  mailer.Mailer
+}
+
+// NewUserService creates a UserService backed by store that sends email through mail
+func NewUserService(store repositories.Store, audit *AuditService, mail mailer.Mailer) *UserService {
+	return &UserService{store: store, audit: audit, mail: mail}
+}
+
 // GetUserByID fetches user data by ID
-func GetUserByID(userID uuid.UUID) (*models.User, error) {
-	log.Printf("Fetching user with ID: %s", userID)
-	var user models.User
-	if err := config.DB.Where("id = ?", userID).First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+func (s *UserService) GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUserByID")
+	defer span.End()
+
+	logging.FromContext(ctx).Debug("fetching user", "user_id", userID)
+	user, err := s.store.WithContext(ctx).Users().FindByID(userID)
+	if err != nil {
+		if errors.Is(err, serviceErrors.ErrRecordNotFound) {
 			return nil, serviceErrors.ErrUserNotFound
 		}
 		return nil, err
 	}
-	return &user, nil
+	return user, nil
 }
 
-// UpdateUserProfile updates username, name, and email for a user
-func UpdateUserProfile(userID uuid.UUID, newUsername, newName, newEmail string) error {
-	var user models.User
-	if err := config.DB.Where("id = ?", userID).First(&user).Error; err != nil {
-		return serviceErrors.ErrUserNotFound
-	}
+// UpdateUserProfile updates username and name for a user. The email address may be sent
+// unchanged; changing it goes through RequestEmailChange.
+func (s *UserService) UpdateUserProfile(ctx context.Context, userID uuid.UUID, newUsername, newName, newEmail string) error {
+	ctx, span := tracing.Start(ctx, "UserService.UpdateUserProfile")
+	defer span.End()
 
-	// Check for username uniqueness if changed
-	if newUsername != user.Username {
-		var count int64
-		config.DB.Model(&models.User{}).
-			Where("username = ? AND id != ?", newUsername, userID).
-			Count(&count)
-		if count > 0 {
-			return serviceErrors.ErrUsernameTaken
+	newEmail = normalizeEmail(newEmail)
+	return s.updateUser(ctx, userID, AuditUserUpdated, EventUserUpdated, nil, func(tx repositories.Store, user *models.User) error {
+		// Empty fields keep their stored value; check for username uniqueness if changed
+		if newUsername != "" && newUsername != user.Username {
+			taken, err := tx.Users().UsernameTaken(newUsername, userID)
+			if err != nil {
+				return err
+			}
+			if taken {
+				return serviceErrors.ErrUsernameTaken
+			}
+			user.Username = newUsername
 		}
-		user.Username = newUsername
-	}
 
-	// Check for email uniqueness if changed
-	if newEmail != user.Email {
-		var count int64
-		config.DB.Model(&models.User{}).
-			Where("email = ? AND id != ?", newEmail, userID).
-			Count(&count)
-		if count > 0 {
-			return serviceErrors.ErrEmailTaken
+		// A new email address must be confirmed before it replaces the current one
+		if newEmail != "" && newEmail != user.Email {
+			return serviceErrors.ErrEmailNotEditable
 		}
-		user.Email = newEmail
-	}
 
-	user.Name = newName
-
-	if err := config.DB.Save(&user).Error; err != nil {
-		return serviceErrors.ErrUserUpdateFailed
-	}
-	return nil
+		if newName != "" {
+			user.Name = newName
+		}
+		return nil
+	})
 }
 
 // DeleteUser deletes a user after password confirmation
-func DeleteUser(userID uuid.UUID, password string) error {
-	var user models.User
-	if err := config.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+func (s *UserService) DeleteUser(ctx context.Context, userID uuid.UUID, password string) error {
+	ctx, span := tracing.Start(ctx, "UserService.DeleteUser")
+	defer span.End()
+
+	user, err := s.store.WithContext(ctx).Users().FindByID(userID)
+	if err != nil {
 		return serviceErrors.ErrUserNotFound
 	}
-	if !utils.CheckPasswordHash(password, user.Password) {
+	match, err := checkPassword(ctx, password, user.Password)
+	if err != nil {
+		return err
+	}
+	if !match {
 		return serviceErrors.ErrPasswordMismatch
 	}
-	if err := config.DB.Delete(&user).Error; err != nil {
+	err = s.store.WithContext(ctx).Transaction(func(tx repositories.Store) error {
+		current, err := tx.Users().FindByID(userID)
+		if err != nil {
+			return err
+		}
+		// The password was checked against this hash; a change since then invalidates it
+		if current.Password != user.Password {
+			return serviceErrors.ErrPasswordMismatch
+		}
+		if err := tx.Users().Delete(current); err != nil {
+			return err
+		}
+		return enqueueOutboxEvent(tx, EventUserDeleted, userEventData{ID: user.ID})
+	})
+	switch {
+	case errors.Is(err, serviceErrors.ErrRecordNotFound):
+		return serviceErrors.ErrUserNotFound
+	case errors.Is(err, serviceErrors.ErrPasswordMismatch):
+		return err
+	case err != nil:
 		return serviceErrors.ErrUserDeleteFailed
 	}
+
+	s.audit.record(ctx, AuditUserDeleted, &user.ID, nil)
 	return nil
 }
 
 // ChangeUserPassword changes a user's password
-func ChangeUserPassword(userID uuid.UUID, oldPassword, newPassword string) error {
-	var user models.User
-	if err := config.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+func (s *UserService) ChangeUserPassword(ctx context.Context, userID uuid.UUID, oldPassword, newPassword string) error {
+	ctx, span := tracing.Start(ctx, "UserService.ChangeUserPassword")
+	defer span.End()
+
+	user, err := s.store.WithContext(ctx).Users().FindByID(userID)
+	if err != nil {
 		return serviceErrors.ErrUserNotFound
 	}
-	if !utils.CheckPasswordHash(oldPassword, user.Password) {
+	match, err := checkPassword(ctx, oldPassword, user.Password)
+	if err != nil {
+		return err
+	}
+	if !match {
 		return serviceErrors.ErrInvalidPassword
 	}
-	hashedPassword, err := utils.HashPassword(newPassword)
+	if err := checkPasswordReuse(ctx, s.store.WithContext(ctx).Users(), user, newPassword); err != nil {
+		return err
+	}
+	hashedPassword, err := hashPassword(ctx, newPassword)
+	if err != nil {
+		return err
+	}
+	return s.updateUser(ctx, userID, AuditUserPasswordChanged, EventUserPasswordChanged, nil, func(tx repositories.Store, current *models.User) error {
+		// The old password was checked against this hash; a change since then invalidates it
+		if current.Password != user.Password {
+			return serviceErrors.ErrInvalidPassword
+		}
+		if err := rememberPassword(tx.Users(), current); err != nil {
+			return err
+		}
+		current.Password = hashedPassword
+		current.PasswordChangedAt = time.Now()
+		current.MustChangePassword = false
+		return nil
+	})
+}
+
+// GetUserByUsername fetches user data by username
+func (s *UserService) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUserByUsername")
+	defer span.End()
+
+	user, err := s.store.WithContext(ctx).Users().FindByUsername(username)
+	if err != nil {
+		if errors.Is(err, serviceErrors.ErrRecordNotFound) {
+			return nil, serviceErrors.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// SetUserPassword replaces a user's password without the old one and revokes their sessions;
+// a temporary password must be changed at the next login
+func (s *UserService) SetUserPassword(ctx context.Context, userID uuid.UUID, newPassword string, temporary bool) error {
+	ctx, span := tracing.Start(ctx, "UserService.SetUserPassword")
+	defer span.End()
+
+	users := s.store.WithContext(ctx).Users()
+	user, err := users.FindByID(userID)
 	if err != nil {
-		return serviceErrors.ErrPasswordHash
+		return serviceErrors.ErrUserNotFound
 	}
-	user.Password = hashedPassword
-	if err := config.DB.Save(&user).Error; err != nil {
+	if err := checkPasswordReuse(ctx, users, user, newPassword); err != nil {
+		return err
+	}
+	hashedPassword, err := hashPassword(ctx, newPassword)
+	if err != nil {
+		return err
+	}
+	return s.updateUser(ctx, userID, AuditUserPasswordReset, EventUserPasswordChanged, nil, func(tx repositories.Store, user *models.User) error {
+		if err := rememberPassword(tx.Users(), user); err != nil {
+			return err
+		}
+		user.Password = hashedPassword
+		user.PasswordChangedAt = time.Now()
+		user.MustChangePassword = temporary
+		user.TokenVersion++
+		return nil
+	})
+}
+
+// ExpirePassword forces a user to change their password at the next login and revokes their sessions
+func (s *UserService) ExpirePassword(ctx context.Context, userID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "UserService.ExpirePassword")
+	defer span.End()
+
+	return s.updateUser(ctx, userID, AuditUserPasswordExpired, "", nil, func(tx repositories.Store, user *models.User) error {
+		user.MustChangePassword = true
+		user.TokenVersion++
+		return nil
+	})
+}
+
+// DisableUser blocks a user from logging in and revokes their sessions
+func (s *UserService) DisableUser(ctx context.Context, userID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "UserService.DisableUser")
+	defer span.End()
+
+	return s.updateUser(ctx, userID, AuditUserDisabled, EventUserUpdated, nil, func(tx repositories.Store, user *models.User) error {
+		user.Disabled = true
+		user.TokenVersion++
+		return nil
+	})
+}
+
+// GrantRole sets a user's role
+func (s *UserService) GrantRole(ctx context.Context, userID uuid.UUID, role string) error {
+	ctx, span := tracing.Start(ctx, "UserService.GrantRole")
+	defer span.End()
+
+	if role != models.RoleUser && role != models.RoleAdmin {
+		return serviceErrors.ErrInvalidRole
+	}
+	return s.updateUser(ctx, userID, AuditUserRoleGranted, EventUserUpdated, map[string]string{"role": role}, func(tx repositories.Store, user *models.User) error {
+		user.Role = role
+		return nil
+	})
+}
+
+// RevokeSessions invalidates every token issued to a user so far
+func (s *UserService) RevokeSessions(ctx context.Context, userID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "UserService.RevokeSessions")
+	defer span.End()
+
+	return s.updateUser(ctx, userID, AuditUserSessionsRevoked, "", nil, func(tx repositories.Store, user *models.User) error {
+		user.TokenVersion++
+		return nil
+	})
+}
+
+// updateUser applies change to a user read inside the transaction, so concurrent updates are
+// not overwritten with stale columns, optionally emits an outbox event and records an audit
+// event. Client errors returned by change are passed through.
+func (s *UserService) updateUser(ctx context.Context, userID uuid.UUID, action, event string, metadata map[string]string, change func(tx repositories.Store, user *models.User) error) error {
+	err := s.store.WithContext(ctx).Transaction(func(tx repositories.Store) error {
+		user, err := tx.Users().FindByID(userID)
+		if err != nil {
+			return err
+		}
+		if err := change(tx, user); err != nil {
+			return err
+		}
+		if err := tx.Users().Update(user); err != nil {
+			return err
+		}
+		if event == "" {
+			return nil
+		}
+		if event == EventUserPasswordChanged {
+			return enqueueOutboxEvent(tx, event, userEventData{ID: user.ID})
+		}
+		return enqueueOutboxEvent(tx, event, newUserEventData(user))
+	})
+	if _, internal := serviceErrors.ToAPIError(err); err != nil && !internal {
+		return err
+	}
+	switch {
+	case errors.Is(err, serviceErrors.ErrRecordNotFound):
+		return serviceErrors.ErrUserNotFound
+	case err != nil:
 		return serviceErrors.ErrUserUpdateFailed
 	}
+
+	s.audit.record(ctx, action, &userID, metadata)
 	return nil
 }
+
+// normalizeEmail lowercases an email address so it is stored the way it is compared
+func normalizeEmail(email string) string {
+	return strings.ToLower(email)
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	serviceErrors "azyqs-auth-systems/errors"
+	"azyqs-auth-systems/models"
+	"azyqs-auth-systems/repositories"
+	"azyqs-auth-systems/utils"
+	"azyqs-auth-systems/validators"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPasswordHistoryIsPruned(t *testing.T) {
+	defer func(history int) { validators.Policy.History = history }(validators.Policy.History)
+	validators.Policy.History = 3
+
+	stores := map[string]repositories.Store{
+		"memory": repositories.NewMemoryStore(),
+		"sqlite": repositories.NewGormStore(newSQLiteDB(t)),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			_, users, _, _ := newTestServices(store)
+			hash, err := hashPassword(ctx, "Passw0rd!")
+			if err != nil {
+				t.Fatalf("hashPassword failed: %v", err)
+			}
+			user := &models.User{Username: "alice", Name: "Alice", Email: "alice@example.com", Password: hash}
+			if err := store.Users().Create(user); err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+
+			for _, password := range []string{"Sec0nd#Pass", "Th1rd#Pass", "F0urth#Pass", "F1fth#Pass"} {
+				if err := users.SetUserPassword(ctx, user.ID, password, false); err != nil {
+					t.Fatalf("SetUserPassword(%q) failed: %v", password, err)
+				}
+			}
+			if err := users.SetUserPassword(ctx, user.ID, "F0urth#Pass", false); !errors.Is(err, serviceErrors.ErrPasswordReused) {
+				t.Fatalf("expected a reset to a recent password to fail with password_reused, got %v", err)
+			}
+
+			history, err := store.Users().ListPasswordHistory(user.ID, 10)
+			if err != nil {
+				t.Fatalf("ListPasswordHistory failed: %v", err)
+			}
+			if len(history) != 2 || !utils.CheckPasswordHash("F0urth#Pass", history[0].Hash) || !utils.CheckPasswordHash("Th1rd#Pass", history[1].Hash) {
+				t.Fatalf("expected the two previous passwords newest first, got %d entries", len(history))
+			}
+
+			if err := users.DeleteUser(ctx, user.ID, "F1fth#Pass"); err != nil {
+				t.Fatalf("DeleteUser failed: %v", err)
+			}
+			history, err = store.Users().ListPasswordHistory(user.ID, 10)
+			if err != nil || len(history) != 0 {
+				t.Fatalf("expected the history to be deleted with the user, got %d entries (%v)", len(history), err)
+			}
+		})
+	}
+}
+
+func TestUsersAreUniqueInAnyCase(t *testing.T) {
+	stores := map[string]repositories.Store{
+		"memory": repositories.NewMemoryStore(),
+		"sqlite": repositories.NewGormStore(newSQLiteDB(t)),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			users := store.Users()
+			if err := users.Create(&models.User{Username: "bob", Name: "Bob", Email: "bob@example.com"}); err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+
+			duplicates := map[string]*models.User{
+				"username": {Username: "Bob", Name: "Bob", Email: "other@example.com"},
+				"email":    {Username: "other", Name: "Bob", Email: "BOB@example.com"},
+			}
+			for field, user := range duplicates {
+				if err := users.Create(user); !errors.Is(err, serviceErrors.ErrDuplicateRecord) {
+					t.Errorf("expected the %s in another case to be a duplicate, got %v", field, err)
+				}
+			}
+		})
+	}
+}
+
+// interleavingStore runs before once, ahead of the first transaction, to simulate a concurrent
+// update between a service's first read and its write
+type interleavingStore struct {
+	repositories.Store
+	before func()
+}
+
+func (s *interleavingStore) WithContext(ctx context.Context) repositories.Store {
+	return s
+}
+
+func (s *interleavingStore) Transaction(fn func(tx repositories.Store) error) error {
+	if s.before != nil {
+		before := s.before
+		s.before = nil
+		before()
+	}
+	return s.Store.Transaction(fn)
+}
+
+func TestUpdatesDoNotOverwriteConcurrentChanges(t *testing.T) {
+	stores := map[string]repositories.Store{
+		"memory": repositories.NewMemoryStore(),
+		"sqlite": repositories.NewGormStore(newSQLiteDB(t)),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			_, direct, _, _ := newTestServices(store)
+			racing := &interleavingStore{Store: store}
+			_, users, _, _ := newTestServices(racing)
+
+			hash, err := hashPassword(ctx, "Passw0rd!")
+			if err != nil {
+				t.Fatalf("hashPassword failed: %v", err)
+			}
+			user := &models.User{Username: "alice", Name: "Alice", Email: "alice@example.com", Password: hash}
+			if err := store.Users().Create(user); err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+
+			// A revocation during a profile edit stays in effect
+			racing.before = func() {
+				if err := direct.RevokeSessions(ctx, user.ID); err != nil {
+					t.Fatalf("RevokeSessions failed: %v", err)
+				}
+			}
+			if err := users.UpdateUserProfile(ctx, user.ID, "", "Alice L.", ""); err != nil {
+				t.Fatalf("UpdateUserProfile failed: %v", err)
+			}
+			stored, err := store.Users().FindByID(user.ID)
+			if err != nil || stored.TokenVersion != 1 || stored.Name != "Alice L." {
+				t.Fatalf("expected the edit and the revocation to both apply, got %+v (%v)", stored, err)
+			}
+
+			// A password reset during a password change or deletion invalidates the checked password
+			racing.before = func() {
+				if err := direct.SetUserPassword(ctx, user.ID, "Res3t#Pass", false); err != nil {
+					t.Fatalf("SetUserPassword failed: %v", err)
+				}
+			}
+			if err := users.ChangeUserPassword(ctx, user.ID, "Passw0rd!", "Chang3d#Pass"); !errors.Is(err, serviceErrors.ErrInvalidPassword) {
+				t.Fatalf("expected the change to fail with invalid_password, got %v", err)
+			}
+			racing.before = func() {
+				if err := direct.SetUserPassword(ctx, user.ID, "Res3t#Again", false); err != nil {
+					t.Fatalf("SetUserPassword failed: %v", err)
+				}
+			}
+			if err := users.DeleteUser(ctx, user.ID, "Res3t#Pass"); !errors.Is(err, serviceErrors.ErrPasswordMismatch) {
+				t.Fatalf("expected the deletion to fail with password_mismatch, got %v", err)
+			}
+			stored, err = store.Users().FindByID(user.ID)
+			if err != nil || !utils.CheckPasswordHash("Res3t#Again", stored.Password) {
+				t.Fatalf("expected the reset password to be kept (%v)", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,366 @@
+package services
+
+import (
+	serviceErrors "azyqs-auth-systems/errors"
+	"azyqs-auth-systems/logging"
+	"azyqs-auth-systems/models"
+	"azyqs-auth-systems/repositories"
+	"azyqs-auth-systems/tracing"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// User lifecycle events emitted through the outbox
+const (
+	EventUserRegistered      = "user.registered"
+	EventUserUpdated         = "user.updated"
+	EventUserDeleted         = "user.deleted"
+	EventUserPasswordChanged = "user.password_changed"
+)
+
+// WebhookEventTypes lists every event type an endpoint can subscribe to
+var WebhookEventTypes = []string{
+	EventUserRegistered,
+	EventUserUpdated,
+	EventUserDeleted,
+	EventUserPasswordChanged,
+}
+
+const (
+	webhookMaxAttempts  = 8
+	webhookBaseBackoff  = 30 * time.Second
+	webhookMaxBackoff   = 6 * time.Hour
+	webhookClaimLease   = 2 * time.Minute
+	webhookBatchSize    = 50
+	webhookTimeout      = 10 * time.Second
+	webhookErrorMaxSize = 512
+)
+
+// webhookEnvelope is the JSON body posted to webhook endpoints
+type webhookEnvelope struct {
+	ID        uuid.UUID       `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// userEventData is the payload describing a user in lifecycle events
+type userEventData struct {
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username,omitempty"`
+	Name     string    `json:"name,omitempty"`
+	Email    string    `json:"email,omitempty"`
+}
+
+func newUserEventData(user *models.User) userEventData {
+	return userEventData{
+		ID:       user.ID,
+		Username: user.Username,
+		Name:     user.Name,
+		Email:    user.Email,
+	}
+}
+
+// enqueueOutboxEvent writes an event to the outbox using the caller's transaction
+func enqueueOutboxEvent(tx repositories.Store, eventType string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return tx.Webhooks().CreateOutboxEvent(&models.OutboxEvent{Type: eventType, Payload: string(payload)})
+}
+
+// WebhookService manages webhook endpoints and delivers outbox events to them
+type WebhookService struct {
+	store  repositories.Store
+	client *http.Client
+}
+
+// NewWebhookService creates a WebhookService backed by store
+func NewWebhookService(store repositories.Store) *WebhookService {
+	return &WebhookService{
+		store:  store,
+		client: &http.Client{Timeout: webhookTimeout, Transport: otelhttp.NewTransport(http.DefaultTransport)},
+	}
+}
+
+// CreateWebhookEndpoint registers a new endpoint and returns it with its signing secret
+func (s *WebhookService) CreateWebhookEndpoint(ctx context.Context, url string, events []string, secret string) (*models.WebhookEndpoint, string, error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.CreateWebhookEndpoint")
+	defer span.End()
+
+	if secret == "" {
+		generated, err := generateWebhookSecret()
+		if err != nil {
+			return nil, "", err
+		}
+		secret = generated
+	}
+
+	endpoint := models.WebhookEndpoint{
+		URL:    url,
+		Secret: secret,
+		Events: strings.Join(events, ","),
+		Active: true,
+	}
+	if err := s.store.WithContext(ctx).Webhooks().CreateEndpoint(&endpoint); err != nil {
+		return nil, "", err
+	}
+	return &endpoint, secret, nil
+}
+
+// ListWebhookEndpoints returns all configured endpoints
+func (s *WebhookService) ListWebhookEndpoints(ctx context.Context) ([]models.WebhookEndpoint, error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.ListWebhookEndpoints")
+	defer span.End()
+
+	return s.store.WithContext(ctx).Webhooks().ListEndpoints()
+}
+
+// DeleteWebhookEndpoint removes an endpoint; its delivery log is kept
+func (s *WebhookService) DeleteWebhookEndpoint(ctx context.Context, id uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "WebhookService.DeleteWebhookEndpoint")
+	defer span.End()
+
+	err := s.store.WithContext(ctx).Webhooks().DeleteEndpoint(id)
+	if errors.Is(err, serviceErrors.ErrRecordNotFound) {
+		return serviceErrors.ErrWebhookNotFound
+	}
+	return err
+}
+
+// ListWebhookDeliveries returns the most recent deliveries of an endpoint, optionally filtered by status
+func (s *WebhookService) ListWebhookDeliveries(ctx context.Context, endpointID uuid.UUID, status string, limit int) ([]models.WebhookDelivery, error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.ListWebhookDeliveries")
+	defer span.End()
+
+	webhooks := s.store.WithContext(ctx).Webhooks()
+	if _, err := webhooks.FindEndpoint(endpointID); err != nil {
+		if errors.Is(err, serviceErrors.ErrRecordNotFound) {
+			return nil, serviceErrors.ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	return webhooks.ListDeliveries(endpointID, status, limit)
+}
+
+// RetryWebhookDelivery moves a dead delivery back to the pending queue
+func (s *WebhookService) RetryWebhookDelivery(ctx context.Context, id uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "WebhookService.RetryWebhookDelivery")
+	defer span.End()
+
+	err := s.store.WithContext(ctx).Webhooks().RequeueDeadDelivery(id, time.Now())
+	if errors.Is(err, serviceErrors.ErrRecordNotFound) {
+		return serviceErrors.ErrDeliveryNotFound
+	}
+	return err
+}
+
+// RunWebhookDispatcher fans outbox events out to endpoints and delivers them until ctx is done.
+// A delivery in flight when ctx ends is finished; the rest of its batch is retried once its lease expires.
+func (s *WebhookService) RunWebhookDispatcher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.dispatchOutboxEvents(); err != nil {
+			slog.Error("webhook outbox dispatch failed", "error", err)
+		}
+		if err := s.deliverDueWebhooks(ctx); err != nil {
+			slog.Error("webhook delivery failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatchOutboxEvents creates a pending delivery per subscribed endpoint for each new outbox event
+func (s *WebhookService) dispatchOutboxEvents() error {
+	return s.store.Transaction(func(tx repositories.Store) error {
+		webhooks := tx.Webhooks()
+		events, err := webhooks.ClaimOutboxEvents(webhookBatchSize)
+		if err != nil || len(events) == 0 {
+			return err
+		}
+
+		endpoints, err := webhooks.ListActiveEndpoints()
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, event := range events {
+			for _, endpoint := range endpoints {
+				if !endpointSubscribes(&endpoint, event.Type) {
+					continue
+				}
+				delivery := models.WebhookDelivery{
+					EndpointID:    endpoint.ID,
+					EventID:       event.ID,
+					EventType:     event.Type,
+					Status:        models.DeliveryPending,
+					NextAttemptAt: now,
+				}
+				if err := webhooks.CreateDelivery(&delivery); err != nil {
+					return err
+				}
+			}
+			if err := webhooks.MarkOutboxEventProcessed(event.ID, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// deliverDueWebhooks claims due deliveries and posts them to their endpoints
+func (s *WebhookService) deliverDueWebhooks(ctx context.Context) error {
+	deliveries, err := s.store.Webhooks().ClaimDueDeliveries(time.Now(), webhookBatchSize, webhookClaimLease)
+	if err != nil {
+		return err
+	}
+
+	for i := range deliveries {
+		if ctx.Err() != nil {
+			return nil
+		}
+		// The client timeout bounds an attempt, so it is not cut short by shutdown
+		s.deliverWebhook(context.WithoutCancel(ctx), &deliveries[i])
+	}
+	return nil
+}
+
+// deliverWebhook performs one delivery attempt and records the outcome
+func (s *WebhookService) deliverWebhook(ctx context.Context, delivery *models.WebhookDelivery) {
+	ctx, span := tracing.Start(ctx, "WebhookService.deliverWebhook", trace.WithAttributes(
+		attribute.String("webhook.delivery_id", delivery.ID.String()),
+		attribute.String("webhook.event_type", delivery.EventType),
+	))
+	defer span.End()
+
+	webhooks := s.store.WithContext(ctx).Webhooks()
+	endpoint, err := webhooks.FindEndpoint(delivery.EndpointID)
+	if err != nil {
+		s.finishDelivery(ctx, delivery, 0, "endpoint_not_found", true)
+		return
+	}
+	event, err := webhooks.FindOutboxEvent(delivery.EventID)
+	if err != nil {
+		s.finishDelivery(ctx, delivery, 0, "event_not_found", true)
+		return
+	}
+
+	body, err := json.Marshal(webhookEnvelope{
+		ID:        event.ID,
+		Type:      event.Type,
+		CreatedAt: event.CreatedAt,
+		Data:      json.RawMessage(event.Payload),
+	})
+	if err != nil {
+		s.finishDelivery(ctx, delivery, 0, err.Error(), true)
+		return
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		s.finishDelivery(ctx, delivery, 0, err.Error(), true)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-ID", delivery.ID.String())
+	req.Header.Set("X-Webhook-Event", event.Type)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+signWebhookPayload(endpoint.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.finishDelivery(ctx, delivery, 0, err.Error(), false)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		s.finishDelivery(ctx, delivery, resp.StatusCode, "", false)
+		return
+	}
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, webhookErrorMaxSize))
+	s.finishDelivery(ctx, delivery, resp.StatusCode, fmt.Sprintf("unexpected status %d: %s", resp.StatusCode, snippet), false)
+}
+
+// finishDelivery stores the result of an attempt and schedules a retry with backoff if needed
+func (s *WebhookService) finishDelivery(ctx context.Context, delivery *models.WebhookDelivery, statusCode int, lastError string, permanent bool) {
+	delivery.Attempts++
+	delivery.ResponseStatus = statusCode
+	delivery.LastError = lastError
+
+	switch {
+	case lastError == "":
+		delivery.Status = models.DeliverySucceeded
+	case permanent || delivery.Attempts >= webhookMaxAttempts:
+		delivery.Status = models.DeliveryDead
+	default:
+		delivery.NextAttemptAt = time.Now().Add(webhookBackoff(delivery.Attempts))
+	}
+
+	if err := s.store.WithContext(ctx).Webhooks().SaveDelivery(delivery); err != nil {
+		logging.FromContext(ctx).Error("failed to save webhook delivery", "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+// webhookBackoff returns the exponential delay before the next attempt
+func webhookBackoff(attempts int) time.Duration {
+	delay := webhookBaseBackoff << (attempts - 1)
+	if delay <= 0 || delay > webhookMaxBackoff {
+		return webhookMaxBackoff
+	}
+	return delay
+}
+
+func endpointSubscribes(endpoint *models.WebhookEndpoint, eventType string) bool {
+	for _, subscribed := range strings.Split(endpoint.Events, ",") {
+		if subscribed == "*" || subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// signWebhookPayload computes the HMAC-SHA256 signature over "timestamp.body"
+func signWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func generateWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errors.New("webhook_secret_generation_failed")
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
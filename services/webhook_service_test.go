@@ -0,0 +1,143 @@
+package services
+
+import (
+	"azyqs-auth-systems/models"
+	"azyqs-auth-systems/repositories"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhookDeliverySignsAndRetries(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []*http.Request
+		bodies   [][]byte
+		fail     = true
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, r)
+		bodies = append(bodies, body)
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	store := repositories.NewMemoryStore()
+	auth, _, _, webhooks := newTestServices(store)
+	ctx := context.Background()
+	endpoint, secret, err := webhooks.CreateWebhookEndpoint(ctx, receiver.URL, []string{EventUserRegistered}, "")
+	if err != nil {
+		t.Fatalf("CreateWebhookEndpoint failed: %v", err)
+	}
+
+	if err := auth.RegisterUser(ctx, "alice", "Alice", "alice@example.com", "Passw0rd!"); err != nil {
+		t.Fatalf("RegisterUser failed: %v", err)
+	}
+
+	if err := webhooks.dispatchOutboxEvents(); err != nil {
+		t.Fatalf("dispatchOutboxEvents failed: %v", err)
+	}
+	if err := webhooks.deliverDueWebhooks(ctx); err != nil {
+		t.Fatalf("deliverDueWebhooks failed: %v", err)
+	}
+
+	deliveries, _ := webhooks.ListWebhookDeliveries(ctx, endpoint.ID, "", 10)
+	if len(deliveries) != 1 {
+		t.Fatalf("expected one delivery, got %d", len(deliveries))
+	}
+	delivery := deliveries[0]
+	if delivery.Status != models.DeliveryPending || delivery.Attempts != 1 || delivery.ResponseStatus != http.StatusServiceUnavailable {
+		t.Fatalf("expected a pending retry after a 503, got %+v", delivery)
+	}
+	if !delivery.NextAttemptAt.After(time.Now().Add(webhookBaseBackoff / 2)) {
+		t.Fatalf("expected the retry to be backed off, next attempt at %s", delivery.NextAttemptAt)
+	}
+
+	// Make the retry due and let the receiver succeed
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+	delivery.NextAttemptAt = time.Now()
+	store.Webhooks().SaveDelivery(&delivery)
+	if err := webhooks.deliverDueWebhooks(ctx); err != nil {
+		t.Fatalf("deliverDueWebhooks failed: %v", err)
+	}
+
+	deliveries, _ = webhooks.ListWebhookDeliveries(ctx, endpoint.ID, models.DeliverySucceeded, 10)
+	if len(deliveries) != 1 || deliveries[0].Attempts != 2 {
+		t.Fatalf("expected the delivery to succeed on the second attempt, got %+v", deliveries)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	last := received[len(received)-1]
+	body := bodies[len(bodies)-1]
+	want := "sha256=" + signWebhookPayload(secret, last.Header.Get("X-Webhook-Timestamp"), body)
+	if got := last.Header.Get("X-Webhook-Signature"); got != want {
+		t.Fatalf("expected signature %s, got %s", want, got)
+	}
+	if last.Header.Get("X-Webhook-Event") != EventUserRegistered {
+		t.Fatalf("unexpected event header %q", last.Header.Get("X-Webhook-Event"))
+	}
+
+	var envelope struct {
+		Type string `json:"type"`
+		Data struct {
+			Username string `json:"username"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil || envelope.Type != EventUserRegistered || envelope.Data.Username != "alice" {
+		t.Fatalf("unexpected webhook body %s", body)
+	}
+}
+
+func TestWebhookDeliveryDeadLetter(t *testing.T) {
+	store := repositories.NewMemoryStore()
+	_, _, _, webhooks := newTestServices(store)
+	ctx := context.Background()
+	delivery := &models.WebhookDelivery{Status: models.DeliveryPending, Attempts: webhookMaxAttempts - 1}
+	store.Webhooks().CreateDelivery(delivery)
+
+	webhooks.finishDelivery(ctx, delivery, http.StatusInternalServerError, "unexpected status 500", false)
+	if delivery.Status != models.DeliveryDead {
+		t.Fatalf("expected the delivery to be dead after %d attempts, got %s", webhookMaxAttempts, delivery.Status)
+	}
+
+	if err := webhooks.RetryWebhookDelivery(ctx, delivery.ID); err != nil {
+		t.Fatalf("RetryWebhookDelivery failed: %v", err)
+	}
+	requeued, _ := store.Webhooks().ClaimDueDeliveries(time.Now(), 10, time.Minute)
+	if len(requeued) != 1 || requeued[0].Attempts != 0 {
+		t.Fatalf("expected the dead delivery to be requeued, got %+v", requeued)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, webhookBaseBackoff},
+		{2, 2 * webhookBaseBackoff},
+		{5, 16 * webhookBaseBackoff},
+		{20, webhookMaxBackoff},
+		{100, webhookMaxBackoff},
+	}
+	for _, tt := range tests {
+		if got := webhookBackoff(tt.attempts); got != tt.want {
+			t.Errorf("webhookBackoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"azyqs-auth-systems/config"
+
+	"github.com/glebarez/sqlite"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// recordSpans installs a provider recording every span for the duration of the test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestInstrumentGORM(t *testing.T) {
+	recorder := recordSpans(t)
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	if err := InstrumentGORM(db); err != nil {
+		t.Fatalf("InstrumentGORM failed: %v", err)
+	}
+
+	// Without a parent span queries are not traced
+	db.Exec("CREATE TABLE widgets (id integer PRIMARY KEY, name text)")
+	if spans := recorder.Ended(); len(spans) != 0 {
+		t.Fatalf("expected no spans without a parent, got %d", len(spans))
+	}
+
+	ctx, parent := Start(context.Background(), "parent")
+	db.WithContext(ctx).Exec("INSERT INTO widgets (name) VALUES (?)", "a")
+	var count int64
+	db.WithContext(ctx).Table("widgets").Count(&count)
+	db.WithContext(ctx).Exec("INSERT INTO missing (name) VALUES ('x')")
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 4 {
+		t.Fatalf("expected 3 query spans and the parent, got %d", len(spans))
+	}
+	for _, span := range spans[:3] {
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("expected %s to be a child of the parent span", span.Name())
+		}
+	}
+
+	if spans[0].Name() != "gorm.raw" || spans[1].Name() != "gorm.query" {
+		t.Errorf("unexpected span names %s, %s", spans[0].Name(), spans[1].Name())
+	}
+	statement := ""
+	for _, attr := range spans[0].Attributes() {
+		if attr.Key == "db.query.text" {
+			statement = attr.Value.AsString()
+		}
+	}
+	if !strings.HasPrefix(statement, "INSERT INTO widgets") {
+		t.Errorf("expected the SQL statement attribute, got %q", statement)
+	}
+	if spans[2].Status().Code.String() != "Error" {
+		t.Errorf("expected the failed query span to have an error status, got %v", spans[2].Status())
+	}
+}
+
+func TestSetupFileExporter(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	cfg := config.Default().Tracing
+	cfg.Enabled = true
+	cfg.Exporter = config.TracingExporterFile
+	cfg.File = path
+
+	shutdown, err := Setup(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	_, span := Start(context.Background(), "AuthService.LoginUser")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(raw), `"Name":"AuthService.LoginUser"`) {
+		t.Fatalf("expected the span in the trace file, got %q (%v)", raw, err)
+	}
+}
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"context"
+	"errors"
+
+	"azyqs-auth-systems/metrics"
+)
+
+// ErrHashPoolFull dikembalikan HashPool.Do saat semua worker sibuk dan antrean penuh
+var ErrHashPoolFull = errors.New("hash_pool_full")
+
+// Hashing membatasi hashing kata sandi yang berjalan bersamaan; nil berarti hashing
+// langsung di goroutine pemanggil
+var Hashing *HashPool
+
+// HashPool menjalankan hashing kata sandi di sejumlah worker tetap dengan antrean terbatas,
+// sehingga lonjakan login tidak menghabiskan semua CPU
+type HashPool struct {
+	slots chan struct{} // satu per tugas yang berjalan atau menunggu
+	jobs  chan hashJob
+}
+
+type hashJob struct {
+	ctx  context.Context
+	run  func()
+	done chan struct{}
+}
+
+// NewHashPool menjalankan workers goroutine yang berbagi antrean sebesar queueSize
+func NewHashPool(workers, queueSize int) *HashPool {
+	p := &HashPool{
+		slots: make(chan struct{}, workers+queueSize),
+		jobs:  make(chan hashJob, workers+queueSize),
+	}
+	for range workers {
+		go p.work()
+	}
+	return p
+}
+
+func (p *HashPool) work() {
+	for job := range p.jobs {
+		metrics.PasswordHashQueueDepth.Dec()
+		// Pemanggil yang sudah berhenti menunggu tidak butuh hasilnya
+		if job.ctx.Err() == nil {
+			job.run()
+		}
+		<-p.slots
+		close(job.done)
+	}
+}
+
+// Do menjalankan fn di worker dan menunggu sampai selesai. Tanpa tempat di antrean Do langsung
+// mengembalikan ErrHashPoolFull; bila ctx berakhir lebih dulu Do mengembalikan ctx.Err().
+func (p *HashPool) Do(ctx context.Context, fn func()) error {
+	if p == nil {
+		fn()
+		return nil
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		return ErrHashPoolFull
+	}
+	job := hashJob{ctx: ctx, run: fn, done: make(chan struct{})}
+	metrics.PasswordHashQueueDepth.Inc()
+	p.jobs <- job // tidak pernah memblokir karena slots membatasi tugas yang sedang diproses
+
+	select {
+	case <-job.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+
+	"azyqs-auth-systems/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// occupy blocks every worker of pool until the returned function is called
+func occupy(t *testing.T, pool *HashPool, workers int) func() {
+	t.Helper()
+	release := make(chan struct{})
+	for range workers {
+		started := make(chan struct{})
+		go pool.Do(context.Background(), func() {
+			close(started)
+			<-release
+		})
+		<-started
+	}
+	var once sync.Once
+	return func() { once.Do(func() { close(release) }) }
+}
+
+func TestHashPoolRunsJobs(t *testing.T) {
+	pool := NewHashPool(2, 4)
+	ran := false
+	if err := pool.Do(context.Background(), func() { ran = true }); err != nil || !ran {
+		t.Fatalf("expected the job to run, got ran=%v err=%v", ran, err)
+	}
+
+	var inline *HashPool
+	ran = false
+	if err := inline.Do(context.Background(), func() { ran = true }); err != nil || !ran {
+		t.Fatalf("expected a nil pool to run the job inline, got ran=%v err=%v", ran, err)
+	}
+}
+
+func TestHashPoolRejectsWhenSaturated(t *testing.T) {
+	pool := NewHashPool(1, 1)
+	release := occupy(t, pool, 1)
+	defer release()
+
+	// One job fits in the queue, the next one is turned away
+	queued := make(chan error)
+	go func() { queued <- pool.Do(context.Background(), func() {}) }()
+	for testutil.ToFloat64(metrics.PasswordHashQueueDepth) < 1 {
+		runtime.Gosched()
+	}
+	if err := pool.Do(context.Background(), func() { t.Error("rejected job ran") }); !errors.Is(err, ErrHashPoolFull) {
+		t.Fatalf("expected ErrHashPoolFull, got %v", err)
+	}
+
+	release()
+	if err := <-queued; err != nil {
+		t.Fatalf("expected the queued job to finish, got %v", err)
+	}
+	if depth := testutil.ToFloat64(metrics.PasswordHashQueueDepth); depth != 0 {
+		t.Fatalf("expected an empty queue, got depth %v", depth)
+	}
+}
+
+func TestHashPoolStopsWaitingWhenContextEnds(t *testing.T) {
+	pool := NewHashPool(1, 1)
+	release := occupy(t, pool, 1)
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := pool.Do(ctx, func() { t.Error("cancelled job ran") }); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
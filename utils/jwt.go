@@ -8,39 +8,111 @@ import (
 	"github.com/google/uuid"
 )
 
-var SECRET_KEY = []byte("your_secret_key") // Replace with a secure secret key
+// AccessTokenTTL is how long issued tokens stay valid
+var AccessTokenTTL = time.Hour
+
+// PasswordChangeTTL is how long tokens restricted to ScopePasswordChange stay valid
+var PasswordChangeTTL = 15 * time.Minute
+
+// ElevatedTokenTTL is how long tokens issued by re-authentication stay valid
+var ElevatedTokenTTL = 5 * time.Minute
+
+// RecentAuthMaxAge is how long ago the user may have last entered their password for
+// routes that require a recent authentication
+var RecentAuthMaxAge = 5 * time.Minute
+
+// ScopePasswordChange restricts a token to changing the user's password
+const ScopePasswordChange = "password_change"
 
 // Custom error codes for JWT
 var (
-	ErrTokenExpired    = errors.New("token_expired")
-	ErrTokenInvalid    = errors.New("token_invalid")
-	ErrTokenMalformed  = errors.New("token_malformed")
-	ErrTokenUnexpected = errors.New("token_unexpected_signing_method")
-	ErrTokenPayload    = errors.New("invalid_token_payload")
+	ErrTokenExpired      = errors.New("token_expired")
+	ErrTokenInvalid      = errors.New("token_invalid")
+	ErrTokenMalformed    = errors.New("token_malformed")
+	ErrTokenUnexpected   = errors.New("token_unexpected_signing_method")
+	ErrTokenPayload      = errors.New("invalid_token_payload")
+	ErrSigningKeyMissing = errors.New("signing_key_missing")
 )
 
-// GenerateJWT generates a JWT token based on userID
-func GenerateJWT(userID uuid.UUID) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": userID.String(),
-		"exp":     time.Now().Add(time.Hour * 1).Unix(), // Token valid for 72 hours
-	}
+// TokenClaims are the values carried by a validated token
+type TokenClaims struct {
+	UserID       uuid.UUID
+	TokenVersion int
+	Scope        string    // empty for unrestricted tokens
+	AuthTime     time.Time // when the user last proved their credentials; zero if unknown
+	Elevated     bool      // issued by re-authentication, unlike tokens from a login
+}
+
+// GenerateJWT generates a JWT token based on userID and the user's current token version
+func GenerateJWT(userID uuid.UUID, tokenVersion int) (string, error) {
+	now := time.Now()
+	return signJWT(jwt.MapClaims{
+		"user_id":   userID.String(),
+		"ver":       tokenVersion,
+		"auth_time": now.Unix(),
+		"exp":       now.Add(AccessTokenTTL).Unix(),
+	})
+}
+
+// GenerateElevatedJWT generates a short-lived token for a user who just re-entered their
+// password; its "elevated" claim is what routes requiring a recent authentication accept
+func GenerateElevatedJWT(userID uuid.UUID, tokenVersion int) (string, error) {
+	now := time.Now()
+	return signJWT(jwt.MapClaims{
+		"user_id":   userID.String(),
+		"ver":       tokenVersion,
+		"elevated":  true,
+		"auth_time": now.Unix(),
+		"exp":       now.Add(ElevatedTokenTTL).Unix(),
+	})
+}
+
+// GeneratePasswordChangeJWT generates a short-lived token that only allows changing the password
+func GeneratePasswordChangeJWT(userID uuid.UUID, tokenVersion int) (string, error) {
+	now := time.Now()
+	return signJWT(jwt.MapClaims{
+		"user_id":   userID.String(),
+		"ver":       tokenVersion,
+		"scope":     ScopePasswordChange,
+		"auth_time": now.Unix(),
+		"exp":       now.Add(PasswordChangeTTL).Unix(),
+	})
+}
+
+// signJWT signs claims with the active key
+func signJWT(claims jwt.MapClaims) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(SECRET_KEY)
+
+	keyID, secret := Keys.Active()
+	if len(secret) == 0 {
+		return "", ErrSigningKeyMissing
+	}
+	if keyID != "" {
+		token.Header["kid"] = keyID
+	}
+
+	tokenString, err := token.SignedString(secret)
 	if err != nil {
 		return "", err
 	}
 	return tokenString, nil
 }
 
-// ValidateJWT validates the token and returns the userID if valid
-func ValidateJWT(tokenString string) (uuid.UUID, error) {
+// ValidateJWT validates the token and returns its claims if valid
+func ValidateJWT(tokenString string) (*TokenClaims, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		// Ensure the signing method is HMAC
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, ErrTokenUnexpected
 		}
-		return SECRET_KEY, nil
+
+		// Pick the key named by the "kid" header; tokens without one use the legacy key
+		keyID, _ := token.Header["kid"].(string)
+		secret, ok := Keys.Lookup(keyID)
+		if !ok {
+			return nil, ErrTokenInvalid
+		}
+		return secret, nil
 	})
 
 	// Handle parsing errors with detailed responses
@@ -48,27 +120,63 @@ func ValidateJWT(tokenString string) (uuid.UUID, error) {
 		if validationErr, ok := err.(*jwt.ValidationError); ok {
 			switch {
 			case validationErr.Errors&jwt.ValidationErrorMalformed != 0:
-				return uuid.Nil, ErrTokenMalformed
+				return nil, ErrTokenMalformed
 			case validationErr.Errors&jwt.ValidationErrorExpired != 0:
-				return uuid.Nil, ErrTokenExpired
+				return nil, ErrTokenExpired
 			default:
-				return uuid.Nil, ErrTokenInvalid
+				return nil, ErrTokenInvalid
 			}
 		}
-		return uuid.Nil, ErrTokenInvalid
+		return nil, ErrTokenInvalid
 	}
 
 	// Validate claims and extract userID
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		if userIDStr, ok := claims["user_id"].(string); ok {
-			userID, err := uuid.Parse(userIDStr)
-			if err != nil {
-				return uuid.Nil, ErrTokenPayload
+		userIDStr, ok := claims["user_id"].(string)
+		if !ok {
+			return nil, ErrTokenPayload
+		}
+		userID, err := uuid.Parse(userIDStr)
+		if err != nil {
+			return nil, ErrTokenPayload
+		}
+
+		// Tokens issued before versioning carry no "ver" claim and count as version 0
+		version := 0
+		if raw, ok := claims["ver"]; ok {
+			number, ok := raw.(float64)
+			if !ok {
+				return nil, ErrTokenPayload
+			}
+			version = int(number)
+		}
+
+		scope := ""
+		if raw, ok := claims["scope"]; ok {
+			if scope, ok = raw.(string); !ok {
+				return nil, ErrTokenPayload
 			}
-			return userID, nil
 		}
-		return uuid.Nil, ErrTokenPayload
+
+		// Tokens issued before "auth_time" existed never count as a recent authentication
+		var authTime time.Time
+		if raw, ok := claims["auth_time"]; ok {
+			seconds, ok := raw.(float64)
+			if !ok {
+				return nil, ErrTokenPayload
+			}
+			authTime = time.Unix(int64(seconds), 0)
+		}
+
+		elevated := false
+		if raw, ok := claims["elevated"]; ok {
+			if elevated, ok = raw.(bool); !ok {
+				return nil, ErrTokenPayload
+			}
+		}
+
+		return &TokenClaims{UserID: userID, TokenVersion: version, Scope: scope, AuthTime: authTime, Elevated: elevated}, nil
 	}
 
-	return uuid.Nil, ErrTokenInvalid
-}
\ No newline at end of file
+	return nil, ErrTokenInvalid
+}
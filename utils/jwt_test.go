@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+var testSecret = []byte("test_secret_key_for_the_utils_suite")
+
+func TestMain(m *testing.M) {
+	// The legacy key only comes from configuration, so install one for the suite
+	Keys = NewKeyring(testSecret)
+	os.Exit(m.Run())
+}
+
+func signClaims(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return token
+}
+
+func signWithKeyID(t *testing.T, keyID string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = keyID
+	signed, err := token.SignedString(testSecret)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func TestValidateJWTLegacyTokenWithoutVersion(t *testing.T) {
+	userID := uuid.New()
+	token := signClaims(t, jwt.SigningMethodHS256, testSecret, jwt.MapClaims{"user_id": userID.String(), "exp": time.Now().Add(time.Hour).Unix()})
+
+	got, err := ValidateJWT(token)
+	if err != nil || got.UserID != userID || got.TokenVersion != 0 || !got.AuthTime.IsZero() {
+		t.Fatalf("expected legacy token to validate as version 0 without an authentication time, got %+v (%v)", got, err)
+	}
+}
+
+func TestGenerateAndValidateJWT(t *testing.T) {
+	userID := uuid.New()
+
+	token, err := GenerateJWT(userID, 3)
+	if err != nil {
+		t.Fatalf("GenerateJWT returned error: %v", err)
+	}
+
+	got, err := ValidateJWT(token)
+	if err != nil {
+		t.Fatalf("ValidateJWT returned error: %v", err)
+	}
+	if got.UserID != userID || got.TokenVersion != 3 || got.Scope != "" || got.Elevated {
+		t.Fatalf("expected user ID %s version 3 without scope or elevation, got %+v", userID, got)
+	}
+	if time.Since(got.AuthTime) > time.Minute {
+		t.Fatalf("expected the authentication time of a new token to be now, got %v", got.AuthTime)
+	}
+
+	token, err = GenerateElevatedJWT(userID, 3)
+	if err != nil {
+		t.Fatalf("GenerateElevatedJWT returned error: %v", err)
+	}
+	elevated, err := jwt.Parse(token, func(*jwt.Token) (interface{}, error) { return testSecret, nil })
+	if err != nil {
+		t.Fatalf("failed to parse elevated token: %v", err)
+	}
+	claims := elevated.Claims.(jwt.MapClaims)
+	if ttl := time.Duration(claims["exp"].(float64)-claims["auth_time"].(float64)) * time.Second; ttl != ElevatedTokenTTL {
+		t.Fatalf("expected the elevated token to last %v, got %v", ElevatedTokenTTL, ttl)
+	}
+	if got, err = ValidateJWT(token); err != nil || !got.Elevated {
+		t.Fatalf("expected an elevated token, got %+v (%v)", got, err)
+	}
+
+	token, err = GeneratePasswordChangeJWT(userID, 3)
+	if err != nil {
+		t.Fatalf("GeneratePasswordChangeJWT returned error: %v", err)
+	}
+	got, err = ValidateJWT(token)
+	if err != nil || got.Scope != ScopePasswordChange {
+		t.Fatalf("expected a %s token, got %+v (%v)", ScopePasswordChange, got, err)
+	}
+}
+
+func TestValidateJWTErrors(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	future := time.Now().Add(time.Hour).Unix()
+	validUser := uuid.New().String()
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{
+			name:  "expired",
+			token: signClaims(t, jwt.SigningMethodHS256, testSecret, jwt.MapClaims{"user_id": validUser, "exp": time.Now().Add(-time.Minute).Unix()}),
+			want:  ErrTokenExpired,
+		},
+		{
+			name:  "malformed",
+			token: "not.a.jwt",
+			want:  ErrTokenMalformed,
+		},
+		{
+			name:  "garbage",
+			token: "garbage",
+			want:  ErrTokenMalformed,
+		},
+		{
+			name:  "wrong signature",
+			token: signClaims(t, jwt.SigningMethodHS256, []byte("another_secret"), jwt.MapClaims{"user_id": validUser, "exp": future}),
+			want:  ErrTokenInvalid,
+		},
+		{
+			name:  "wrong algorithm",
+			token: signClaims(t, jwt.SigningMethodRS256, rsaKey, jwt.MapClaims{"user_id": validUser, "exp": future}),
+			want:  ErrTokenInvalid,
+		},
+		{
+			name:  "none algorithm",
+			token: signClaims(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, jwt.MapClaims{"user_id": validUser, "exp": future}),
+			want:  ErrTokenInvalid,
+		},
+		{
+			name:  "unknown key id",
+			token: signWithKeyID(t, "unknown", jwt.MapClaims{"user_id": validUser, "exp": future}),
+			want:  ErrTokenInvalid,
+		},
+		{
+			name:  "non numeric version",
+			token: signClaims(t, jwt.SigningMethodHS256, testSecret, jwt.MapClaims{"user_id": validUser, "ver": "1", "exp": future}),
+			want:  ErrTokenPayload,
+		},
+		{
+			name:  "non string scope",
+			token: signClaims(t, jwt.SigningMethodHS256, testSecret, jwt.MapClaims{"user_id": validUser, "scope": 1, "exp": future}),
+			want:  ErrTokenPayload,
+		},
+		{
+			name:  "non numeric auth time",
+			token: signClaims(t, jwt.SigningMethodHS256, testSecret, jwt.MapClaims{"user_id": validUser, "auth_time": "now", "exp": future}),
+			want:  ErrTokenPayload,
+		},
+		{
+			name:  "non boolean elevation",
+			token: signClaims(t, jwt.SigningMethodHS256, testSecret, jwt.MapClaims{"user_id": validUser, "elevated": "yes", "exp": future}),
+			want:  ErrTokenPayload,
+		},
+		{
+			name:  "missing user id",
+			token: signClaims(t, jwt.SigningMethodHS256, testSecret, jwt.MapClaims{"exp": future}),
+			want:  ErrTokenPayload,
+		},
+		{
+			name:  "non uuid user id",
+			token: signClaims(t, jwt.SigningMethodHS256, testSecret, jwt.MapClaims{"user_id": "not-a-uuid", "exp": future}),
+			want:  ErrTokenPayload,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateJWT(tt.token)
+			if err != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+			if got != nil {
+				t.Fatalf("expected no claims, got %+v", got)
+			}
+		})
+	}
+}
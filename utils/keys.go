@@ -0,0 +1,232 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultKeysKept is how many signing keys a rotation keeps for verification
+const DefaultKeysKept = 3
+
+// SigningKey is one HMAC key identified by the "kid" token header
+type SigningKey struct {
+	ID        string    `json:"id"`
+	Secret    string    `json:"secret"` // base64-encoded
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// keyFile is the on-disk format of a keyring, newest key first. Retired keys no longer
+// verify tokens but are kept for the audit checkpoints they signed; the legacy key is
+// retired under the empty ID.
+type keyFile struct {
+	Keys    []SigningKey `json:"keys"`
+	Retired []SigningKey `json:"retired,omitempty"`
+}
+
+// Keyring holds the active signing key and the previous keys still accepted for verification.
+// Without any loaded key it falls back to the legacy key and issues tokens without a "kid".
+type Keyring struct {
+	mu      sync.RWMutex
+	keys    []SigningKey
+	retired []SigningKey
+	legacy  []byte
+}
+
+// Keys is the keyring used to sign and verify tokens; it is empty until configuration is loaded
+var Keys = NewKeyring(nil)
+
+// NewKeyring returns a keyring that only knows the legacy key
+func NewKeyring(legacy []byte) *Keyring {
+	return &Keyring{legacy: legacy}
+}
+
+// Active returns the ID and secret of the key new signatures must use
+func (k *Keyring) Active() (string, []byte) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if len(k.keys) == 0 {
+		return "", k.legacy
+	}
+	secret, _ := base64.StdEncoding.DecodeString(k.keys[0].Secret)
+	return k.keys[0].ID, secret
+}
+
+// Lookup returns the secret for a key ID; the empty ID is the legacy key
+func (k *Keyring) Lookup(id string) ([]byte, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if id == "" {
+		return k.legacy, len(k.legacy) > 0
+	}
+
+	for _, key := range k.keys {
+		if key.ID == id {
+			secret, err := base64.StdEncoding.DecodeString(key.Secret)
+			return secret, err == nil
+		}
+	}
+	return nil, false
+}
+
+// LookupRetained is Lookup extended to retired keys, for checking signatures that outlive
+// tokens such as audit checkpoints
+func (k *Keyring) LookupRetained(id string) ([]byte, bool) {
+	if secret, ok := k.Lookup(id); ok {
+		return secret, true
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	for _, key := range k.retired {
+		if key.ID == id {
+			secret, err := base64.StdEncoding.DecodeString(key.Secret)
+			return secret, err == nil
+		}
+	}
+	return nil, false
+}
+
+// Len returns the number of loaded keys, not counting the legacy key
+func (k *Keyring) Len() int {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return len(k.keys)
+}
+
+// Load replaces the keyring's keys with those stored at path
+func (k *Keyring) Load(path string) error {
+	file, err := loadKeys(path)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	k.keys, k.retired = file.Keys, file.Retired
+	k.mu.Unlock()
+	return nil
+}
+
+// Reload replaces the legacy key and the keys stored at path in one step; an empty path drops
+// the loaded keys. On error the keyring is left unchanged.
+func (k *Keyring) Reload(legacy []byte, path string) error {
+	var file keyFile
+	if path != "" {
+		var err error
+		if file, err = loadKeys(path); err != nil {
+			return err
+		}
+	}
+
+	k.mu.Lock()
+	k.legacy = legacy
+	k.keys, k.retired = file.Keys, file.Retired
+	k.mu.Unlock()
+	return nil
+}
+
+// loadKeys reads and checks the keys stored at path
+func loadKeys(path string) (keyFile, error) {
+	file, err := readKeyFile(path)
+	if err != nil {
+		return file, err
+	}
+	if len(file.Keys) == 0 {
+		return file, errors.New("key_file_empty")
+	}
+	for _, key := range file.Keys {
+		if _, err := base64.StdEncoding.DecodeString(key.Secret); err != nil || key.ID == "" {
+			return file, errors.New("key_file_invalid")
+		}
+	}
+	for _, key := range file.Retired {
+		if _, err := base64.StdEncoding.DecodeString(key.Secret); err != nil {
+			return file, errors.New("key_file_invalid")
+		}
+	}
+	return file, nil
+}
+
+// RotateKeyFile adds a new active key to the key file at path. Only the newest keep keys
+// verify tokens; older ones and legacy, if given, are retired rather than deleted so audit
+// checkpoints signed with them stay verifiable. The file is created if it does not exist.
+func RotateKeyFile(path string, keep int, legacy []byte) (string, error) {
+	file, err := readKeyFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	id := make([]byte, 8)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	key := SigningKey{
+		ID:        hex.EncodeToString(id),
+		Secret:    base64.StdEncoding.EncodeToString(secret),
+		CreatedAt: time.Now().UTC(),
+	}
+	file.Keys = append([]SigningKey{key}, file.Keys...)
+	if keep > 0 && len(file.Keys) > keep {
+		file.Retired = append(append([]SigningKey(nil), file.Keys[keep:]...), file.Retired...)
+		file.Keys = file.Keys[:keep]
+	}
+	if len(legacy) > 0 && !slices.ContainsFunc(file.Retired, func(key SigningKey) bool { return key.ID == "" }) {
+		file.Retired = append(file.Retired, SigningKey{Secret: base64.StdEncoding.EncodeToString(legacy), CreatedAt: key.CreatedAt})
+	}
+
+	if err := writeKeyFile(path, file); err != nil {
+		return "", err
+	}
+	return key.ID, nil
+}
+
+func readKeyFile(path string) (keyFile, error) {
+	var file keyFile
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return file, err
+	}
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return file, errors.New("key_file_invalid")
+	}
+	return file, nil
+}
+
+// writeKeyFile replaces the key file atomically with owner-only permissions
+func writeKeyFile(path string, file keyFile) error {
+	raw, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".keys-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
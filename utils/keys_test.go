@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestKeyRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	previous := Keys
+	Keys = NewKeyring(testSecret)
+	t.Cleanup(func() { Keys = previous })
+
+	legacyToken, err := GenerateJWT(uuid.New(), 0)
+	if err != nil {
+		t.Fatalf("GenerateJWT failed: %v", err)
+	}
+
+	firstID, err := RotateKeyFile(path, 2, testSecret)
+	if err != nil {
+		t.Fatalf("RotateKeyFile failed: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("key file must be owner-only, got %v (%v)", info.Mode(), err)
+	}
+	if err := Keys.Load(path); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if id, _ := Keys.Active(); id != firstID {
+		t.Fatalf("expected active key %s, got %s", firstID, id)
+	}
+
+	firstToken, _ := GenerateJWT(uuid.New(), 0)
+
+	secondID, _ := RotateKeyFile(path, 2, testSecret)
+	if err := Keys.Load(path); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if id, _ := Keys.Active(); id != secondID {
+		t.Fatalf("expected active key %s, got %s", secondID, id)
+	}
+
+	// Tokens signed with the previous key and the legacy key stay valid
+	for name, token := range map[string]string{"legacy": legacyToken, "previous": firstToken} {
+		if _, err := ValidateJWT(token); err != nil {
+			t.Fatalf("%s token rejected after rotation: %v", name, err)
+		}
+	}
+
+	// A third rotation with keep=2 drops the first key
+	RotateKeyFile(path, 2, testSecret)
+	Keys.Load(path)
+	if Keys.Len() != 2 {
+		t.Fatalf("expected 2 keys kept, got %d", Keys.Len())
+	}
+	if _, err := ValidateJWT(firstToken); err != ErrTokenInvalid {
+		t.Fatalf("expected token of a dropped key to be invalid, got %v", err)
+	}
+
+	// Dropped keys and the legacy key are retired, not deleted, even once JWT_SECRET is gone
+	if err := Keys.Reload(nil, path); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if _, ok := Keys.Lookup(""); ok {
+		t.Fatal("expected the legacy key to stop verifying tokens")
+	}
+	for name, id := range map[string]string{"dropped": firstID, "legacy": ""} {
+		if _, ok := Keys.LookupRetained(id); !ok {
+			t.Errorf("expected the %s key to be retained", name)
+		}
+	}
+}
+
+func TestLoadRejectsInvalidKeyFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"empty.json":   `{"keys":[]}`,
+		"garbage.json": `not json`,
+		"badkey.json":  `{"keys":[{"id":"a","secret":"***"}]}`,
+		"noid.json":    `{"keys":[{"id":"","secret":"c2VjcmV0"}]}`,
+	}
+
+	for name, contents := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			os.WriteFile(path, []byte(contents), 0o600)
+			if err := NewKeyring(testSecret).Load(path); err == nil {
+				t.Fatal("expected Load to fail")
+			}
+		})
+	}
+
+	if err := NewKeyring(testSecret).Load(filepath.Join(dir, "missing.json")); err == nil {
+		t.Fatal("expected Load of a missing file to fail")
+	}
+}
+
+func TestKeyringReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	keyID, err := RotateKeyFile(path, DefaultKeysKept, nil)
+	if err != nil {
+		t.Fatalf("RotateKeyFile failed: %v", err)
+	}
+
+	keyring := NewKeyring([]byte("old_legacy_secret"))
+	if err := keyring.Reload([]byte("new_legacy_secret"), path); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if id, _ := keyring.Active(); id != keyID {
+		t.Errorf("expected active key %s, got %q", keyID, id)
+	}
+	if legacy, ok := keyring.Lookup(""); !ok || string(legacy) != "new_legacy_secret" {
+		t.Errorf("expected the legacy key to be replaced, got %q", legacy)
+	}
+
+	// A broken key file leaves the keyring untouched
+	if err := keyring.Reload([]byte("ignored"), filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatal("expected Reload to fail for a missing key file")
+	}
+	if legacy, _ := keyring.Lookup(""); string(legacy) != "new_legacy_secret" || keyring.Len() != 1 {
+		t.Errorf("expected the keyring to be unchanged after a failed reload")
+	}
+
+	// Without a key file only the legacy key remains
+	if err := keyring.Reload([]byte("only_legacy"), ""); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if id, secret := keyring.Active(); id != "" || string(secret) != "only_legacy" {
+		t.Errorf("expected the legacy key to be active, got %q", id)
+	}
+}
@@ -1,17 +1,161 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"azyqs-auth-systems/metrics"
+
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// HashPassword meng-hash password menggunakan bcrypt
+// PasswordHasher membuat dan memverifikasi hash kata sandi dalam format PHC
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Handles melaporkan apakah hash dibuat dengan algoritma hasher ini
+	Handles(hash string) bool
+	// Verify membandingkan password dengan hash; parameter dibaca dari hash itu sendiri
+	Verify(password, hash string) bool
+	// Current melaporkan apakah hash memakai algoritma dan parameter hasher ini
+	Current(hash string) bool
+}
+
+// Hasher dipakai HashPassword untuk hash baru; hash algoritma lain tetap bisa diverifikasi
+var Hasher PasswordHasher = DefaultArgon2id
+
+// DefaultArgon2id mengikuti rekomendasi minimum OWASP untuk argon2id
+var DefaultArgon2id = Argon2idHasher{Memory: 19 * 1024, Iterations: 2, Parallelism: 1}
+
+// hashers adalah semua algoritma yang dikenali CheckPasswordHash
+var hashers = []PasswordHasher{Argon2idHasher{}, BcryptHasher{}}
+
+// HashPassword meng-hash password dengan Hasher
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	return string(bytes), err
+	defer observeHash("hash", time.Now())
+	return Hasher.Hash(password)
 }
 
-// CheckPasswordHash membandingkan password dengan hash
+// CheckPasswordHash membandingkan password dengan hash dari algoritma apa pun yang dikenali
 func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+	defer observeHash("compare", time.Now())
+	for _, hasher := range hashers {
+		if hasher.Handles(hash) {
+			return hasher.Verify(password, hash)
+		}
+	}
+	return false
+}
+
+// NeedsRehash melaporkan apakah hash dibuat dengan algoritma atau parameter selain milik Hasher
+func NeedsRehash(hash string) bool {
+	return !Hasher.Current(hash)
+}
+
+// observeHash mencatat durasi operasi hashing sejak start
+func observeHash(operation string, start time.Time) {
+	metrics.PasswordHashDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// BcryptMaxPasswordBytes adalah panjang kata sandi terbesar, dalam byte, yang diterima bcrypt
+const BcryptMaxPasswordBytes = 72
+
+// BcryptHasher memakai bcrypt; hash-nya berformat $2a$/$2b$ yang juga dipakai PHC
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	return string(bytes), err
+}
+
+func (h BcryptHasher) Handles(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func (h BcryptHasher) Verify(password, hash string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+func (h BcryptHasher) Current(hash string) bool {
+	if !h.Handles(hash) {
+		return false
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err == nil && cost == h.Cost
+}
+
+// Argon2idHasher memakai argon2id; Memory dalam KiB
+type Argon2idHasher struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+const (
+	argon2idPrefix    = "$argon2id$"
+	argon2SaltLength  = 16
+	argon2KeyLength   = 32
+	argon2PHCSections = 6 // "", argon2id, v=19, m=..,t=..,p=.., salt, hash
+)
+
+var errInvalidArgon2Hash = errors.New("invalid argon2id hash")
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, argon2KeyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version, h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h Argon2idHasher) Handles(hash string) bool {
+	return strings.HasPrefix(hash, argon2idPrefix)
+}
+
+func (h Argon2idHasher) Verify(password, hash string) bool {
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return false
+	}
+	actual := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(actual, key) == 1
+}
+
+func (h Argon2idHasher) Current(hash string) bool {
+	params, _, _, err := parseArgon2id(hash)
+	return err == nil && params == h
+}
+
+// parseArgon2id membaca parameter, salt, dan kunci dari hash PHC argon2id
+func parseArgon2id(hash string) (params Argon2idHasher, salt, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != argon2PHCSections || parts[1] != "argon2id" {
+		return params, nil, nil, errInvalidArgon2Hash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errInvalidArgon2Hash
+	}
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil || params.Iterations == 0 || params.Parallelism == 0 {
+		return params, nil, nil, errInvalidArgon2Hash
+	}
+
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, errInvalidArgon2Hash
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return params, nil, nil, errInvalidArgon2Hash
+	}
+	return params, salt, key, nil
 }
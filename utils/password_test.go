@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fastArgon2id keeps the suite quick; production parameters come from configuration
+var fastArgon2id = Argon2idHasher{Memory: 64, Iterations: 1, Parallelism: 1}
+
+func TestPasswordHashers(t *testing.T) {
+	hashers := map[string]PasswordHasher{
+		"argon2id": fastArgon2id,
+		"bcrypt":   BcryptHasher{Cost: bcrypt.MinCost},
+	}
+	for name, hasher := range hashers {
+		t.Run(name, func(t *testing.T) {
+			defer func(previous PasswordHasher) { Hasher = previous }(Hasher)
+			Hasher = hasher
+
+			hash, err := HashPassword("Passw0rd!")
+			if err != nil {
+				t.Fatalf("HashPassword failed: %v", err)
+			}
+			if !CheckPasswordHash("Passw0rd!", hash) || CheckPasswordHash("Passw0rd?", hash) {
+				t.Fatalf("expected only the original password to match %q", hash)
+			}
+			if NeedsRehash(hash) {
+				t.Fatalf("expected a fresh hash to be current: %q", hash)
+			}
+		})
+	}
+}
+
+func TestArgon2idHashFormat(t *testing.T) {
+	hash, err := fastArgon2id.Hash("Passw0rd!")
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") || strings.Count(hash, "$") != 5 {
+		t.Fatalf("expected a PHC argon2id string, got %q", hash)
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	defer func(previous PasswordHasher) { Hasher = previous }(Hasher)
+
+	bcryptHash, _ := BcryptHasher{Cost: bcrypt.MinCost}.Hash("Passw0rd!")
+	argonHash, _ := fastArgon2id.Hash("Passw0rd!")
+
+	tests := []struct {
+		name   string
+		hasher PasswordHasher
+		hash   string
+		want   bool
+	}{
+		{"same bcrypt cost", BcryptHasher{Cost: bcrypt.MinCost}, bcryptHash, false},
+		{"higher bcrypt cost", BcryptHasher{Cost: bcrypt.MinCost + 1}, bcryptHash, true},
+		{"bcrypt to argon2id", fastArgon2id, bcryptHash, true},
+		{"same argon2id parameters", fastArgon2id, argonHash, false},
+		{"more argon2id memory", Argon2idHasher{Memory: 128, Iterations: 1, Parallelism: 1}, argonHash, true},
+		{"argon2id to bcrypt", BcryptHasher{Cost: bcrypt.MinCost}, argonHash, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Hasher = tt.hasher
+			if got := NeedsRehash(tt.hash); got != tt.want {
+				t.Fatalf("expected NeedsRehash %v, got %v", tt.want, got)
+			}
+			if !CheckPasswordHash("Passw0rd!", tt.hash) {
+				t.Fatal("expected the hash to verify whatever the current hasher is")
+			}
+		})
+	}
+}
+
+func TestCheckPasswordHashRejectsMalformedHashes(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"plaintext",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdA",
+		"$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHQ$aGFzaA",
+		"$argon2id$v=19$m=64,t=0,p=1$c2FsdHNhbHQ$aGFzaA",
+		"$argon2id$v=19$m=64,t=1,p=1$!!!$aGFzaA",
+		"$2b$04$short",
+	} {
+		if CheckPasswordHash("Passw0rd!", hash) {
+			t.Errorf("expected %q to be rejected", hash)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package validators
+
+import (
+	serviceErrors "azyqs-auth-systems/errors"
+	"errors"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PasswordPolicy adalah aturan kata sandi baru; nilai nol menonaktifkan aturan angka
+type PasswordPolicy struct {
+	MinLength            int     `json:"min_length"`
+	MaxLength            int     `json:"max_length"`
+	RequireUppercase     bool    `json:"require_uppercase"`
+	RequireLowercase     bool    `json:"require_lowercase"`
+	RequireDigit         bool    `json:"require_digit"`
+	RequireSpecial       bool    `json:"require_special"`
+	MaxRepeated          int     `json:"max_repeated"`           // karakter sama berturut-turut
+	DisallowPersonalInfo bool    `json:"disallow_personal_info"` // username, nama, email
+	MinEntropy           float64 `json:"min_entropy"`            // perkiraan bit, lihat PasswordEntropy
+	History              int     `json:"history"`                // kata sandi terakhir, termasuk yang aktif, yang tidak boleh dipakai ulang
+	MaxAgeDays           int     `json:"max_age_days"`           // umur kata sandi sebelum wajib diganti
+}
+
+// Policy adalah kebijakan yang dipakai ValidatePassword; ditimpa dari konfigurasi saat start
+var Policy = PasswordPolicy{
+	MinLength:            8,
+	MaxLength:            72,
+	RequireUppercase:     true,
+	RequireLowercase:     true,
+	RequireDigit:         true,
+	RequireSpecial:       true,
+	MaxRepeated:          3,
+	DisallowPersonalInfo: true,
+	MinEntropy:           40,
+	History:              5,
+}
+
+// MaxPasswordBytes, jika diisi, membatasi panjang kata sandi baru dalam byte, di samping
+// MaxLength yang dihitung dalam karakter; diisi saat start bila hashing memakai bcrypt
+var MaxPasswordBytes int
+
+// Breached, jika diisi, dipakai ValidatePassword untuk menolak kata sandi yang pernah bocor
+var Breached interface {
+	Contains(password string) bool
+}
+
+// minPersonalInfoLength adalah panjang minimum bagian data pribadi yang diperiksa
+const minPersonalInfoLength = 3
+
+// ValidatePassword memeriksa kata sandi baru terhadap Policy dan daftar Breached, lalu
+// mengembalikan semua aturan yang dilanggar; personal berisi username, nama, dan email pemiliknya
+func ValidatePassword(password string, personal ...string) error {
+	err := Policy.Check(password, personal...)
+	if MaxPasswordBytes > 0 && len(password) > MaxPasswordBytes && !errors.Is(err, serviceErrors.ErrPasswordTooLong) {
+		err = errors.Join(err, serviceErrors.ErrPasswordTooLong)
+	}
+	if Breached != nil && Breached.Contains(password) {
+		err = errors.Join(err, serviceErrors.ErrPasswordCompromised)
+	}
+	return err
+}
+
+// RequirePassword hanya memastikan kata sandi diisi, untuk kata sandi yang sudah ada
+// (login, konfirmasi) yang mungkin dibuat dengan kebijakan lama
+func RequirePassword(password string) error {
+	if password == "" {
+		return serviceErrors.ErrPasswordRequired
+	}
+	return nil
+}
+
+// Check mengembalikan semua aturan p yang dilanggar password, digabung dengan errors.Join
+func (p PasswordPolicy) Check(password string, personal ...string) error {
+	var errs []error
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		errs = append(errs, serviceErrors.ErrPasswordTooShort)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		errs = append(errs, serviceErrors.ErrPasswordTooLong)
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSpecial bool
+	for _, char := range password {
+		switch {
+		case unicode.IsUpper(char):
+			hasUpper = true
+		case unicode.IsLower(char):
+			hasLower = true
+		case unicode.IsDigit(char):
+			hasDigit = true
+		case unicode.IsPunct(char) || unicode.IsSymbol(char):
+			hasSpecial = true
+		}
+	}
+	if p.RequireUppercase && !hasUpper {
+		errs = append(errs, serviceErrors.ErrPasswordNoUppercase)
+	}
+	if p.RequireLowercase && !hasLower {
+		errs = append(errs, serviceErrors.ErrPasswordNoLowercase)
+	}
+	if p.RequireDigit && !hasDigit {
+		errs = append(errs, serviceErrors.ErrPasswordNoDigit)
+	}
+	if p.RequireSpecial && !hasSpecial {
+		errs = append(errs, serviceErrors.ErrPasswordNoSpecial)
+	}
+
+	if p.MaxRepeated > 0 && longestRun(password) > p.MaxRepeated {
+		errs = append(errs, serviceErrors.ErrPasswordRepeated)
+	}
+	if p.DisallowPersonalInfo && containsPersonalInfo(password, personal) {
+		errs = append(errs, serviceErrors.ErrPasswordPersonalInfo)
+	}
+	if p.MinEntropy > 0 && PasswordEntropy(password) < p.MinEntropy {
+		errs = append(errs, serviceErrors.ErrPasswordTooWeak)
+	}
+	return errors.Join(errs...)
+}
+
+// PasswordEntropy memperkirakan kekuatan kata sandi dalam bit: panjang dikali log2
+// ukuran kumpulan karakter yang dipakai
+func PasswordEntropy(password string) float64 {
+	var lower, upper, digit, special, other bool
+	for _, char := range password {
+		switch {
+		case char >= 'a' && char <= 'z':
+			lower = true
+		case char >= 'A' && char <= 'Z':
+			upper = true
+		case char >= '0' && char <= '9':
+			digit = true
+		case char < utf8.RuneSelf && (unicode.IsPunct(char) || unicode.IsSymbol(char) || char == ' '):
+			special = true
+		default:
+			other = true
+		}
+	}
+
+	pool := 0
+	for _, class := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {special, 33}, {other, 100}} {
+		if class.used {
+			pool += class.size
+		}
+	}
+	if pool == 0 {
+		return 0
+	}
+	return float64(utf8.RuneCountInString(password)) * math.Log2(float64(pool))
+}
+
+// longestRun mengembalikan jumlah terbanyak karakter sama yang berturut-turut
+func longestRun(password string) int {
+	longest, run := 0, 0
+	var previous rune = -1
+	for _, char := range password {
+		if char == previous {
+			run++
+		} else {
+			run = 1
+		}
+		previous = char
+		longest = max(longest, run)
+	}
+	return longest
+}
+
+// containsPersonalInfo memeriksa apakah password memuat username, bagian lokal email,
+// atau kata dari nama, tanpa membedakan huruf besar/kecil
+func containsPersonalInfo(password string, personal []string) bool {
+	password = strings.ToLower(password)
+	for _, value := range personal {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if local, _, ok := strings.Cut(value, "@"); ok {
+			value = local
+		}
+		for _, part := range append(strings.Fields(value), value) {
+			if utf8.RuneCountInString(part) >= minPersonalInfoLength && strings.Contains(password, part) {
+				return true
+			}
+		}
+	}
+	return false
+}
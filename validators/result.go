@@ -0,0 +1,34 @@
+package validators
+
+import serviceErrors "azyqs-auth-systems/errors"
+
+// Result mengumpulkan semua kegagalan validasi per field agar dilaporkan sekaligus
+type Result struct {
+	fields map[string][]string
+}
+
+// Check mencatat err sebagai kegagalan field jika err tidak nil; error gabungan
+// (errors.Join) dicatat satu per satu
+func (r *Result) Check(field string, err error) {
+	if err == nil {
+		return
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			r.Check(field, e)
+		}
+		return
+	}
+	if r.fields == nil {
+		r.fields = make(map[string][]string)
+	}
+	r.fields[field] = append(r.fields[field], err.Error())
+}
+
+// Err mengembalikan error validasi berisi semua kegagalan, atau nil jika input valid
+func (r *Result) Err() error {
+	if len(r.fields) == 0 {
+		return nil
+	}
+	return serviceErrors.ValidationError(r.fields)
+}
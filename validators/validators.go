@@ -1,10 +1,10 @@
 package validators
 
 import (
-	"errors"
+	serviceErrors "azyqs-auth-systems/errors"
+	"net/url"
 	"regexp"
 	"strings"
-	"unicode"
 )
 
 // Regex untuk validasi email
@@ -13,13 +13,10 @@ var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]
 // Regex untuk validasi username (huruf, angka, titik, tanpa titik di awal/akhir)
 var usernameRegex = regexp.MustCompile(`^[a-zA-Z0-9]+(\.[a-zA-Z0-9]+)*$`)
 
-// Regex sederhana untuk validasi panjang password
-var passwordRegex = regexp.MustCompile(`^.{8,}$`)
-
 // ValidateEmail memastikan email dalam format yang benar
 func ValidateEmail(email string) error {
 	if !emailRegex.MatchString(email) {
-		return errors.New("invalid_email_format")
+		return serviceErrors.ErrInvalidEmailFormat
 	}
 	return nil
 }
@@ -27,16 +24,24 @@ func ValidateEmail(email string) error {
 // ValidateUsername memastikan username sesuai aturan
 func ValidateUsername(username string) error {
 	if len(username) < 3 {
-		return errors.New("username_too_short")
+		return serviceErrors.ErrUsernameTooShort
 	}
 	if len(username) > 32 {
-		return errors.New("username_too_long")
+		return serviceErrors.ErrUsernameTooLong
 	}
 	if !usernameRegex.MatchString(username) {
-		return errors.New("invalid_username_format")
+		return serviceErrors.ErrInvalidUsernameFormat
 	}
 	if strings.HasPrefix(username, ".") || strings.HasSuffix(username, ".") {
-		return errors.New("username_cannot_start_or_end_with_dot")
+		return serviceErrors.ErrUsernameDotEdge
+	}
+	return nil
+}
+
+// RequireToken memastikan token dari tautan email diisi
+func RequireToken(token string) error {
+	if token == "" {
+		return serviceErrors.ErrEmailTokenRequired
 	}
 	return nil
 }
@@ -45,37 +50,45 @@ func ValidateUsername(username string) error {
 func ValidateName(name string) error {
 	name = strings.TrimSpace(name)
 	if len(name) < 2 {
-		return errors.New("name_too_short")
+		return serviceErrors.ErrNameTooShort
 	}
 	if len(name) > 32 {
-		return errors.New("name_too_long")
+		return serviceErrors.ErrNameTooLong
 	}
 	return nil
 }
 
-// ValidatePassword memastikan password memenuhi syarat
-func ValidatePassword(password string) error {
-	if !passwordRegex.MatchString(password) {
-		return errors.New("password_too_short")
+// ValidateWebhookURL memastikan URL webhook absolut dengan skema http/https
+func ValidateWebhookURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return serviceErrors.ErrInvalidWebhookURL
 	}
-
-	var hasUpper, hasLower, hasNumber, hasSpecial bool
-	for _, char := range password {
-		switch {
-		case unicode.IsUpper(char):
-			hasUpper = true
-		case unicode.IsLower(char):
-			hasLower = true
-		case unicode.IsDigit(char):
-			hasNumber = true
-		case unicode.IsPunct(char) || unicode.IsSymbol(char):
-			hasSpecial = true
-		}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return serviceErrors.ErrInvalidWebhookURL
 	}
+	return nil
+}
 
-	if !hasUpper || !hasLower || !hasNumber || !hasSpecial {
-		return errors.New("password_must_include_upper_lower_digit_special")
+// ValidateWebhookEvents memastikan setiap event dikenal atau "*"
+func ValidateWebhookEvents(events, known []string) error {
+	if len(events) == 0 {
+		return serviceErrors.ErrWebhookEventsRequired
+	}
+	for _, event := range events {
+		if event == "*" {
+			continue
+		}
+		found := false
+		for _, k := range known {
+			if event == k {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return serviceErrors.ErrUnknownWebhookEvent
+		}
 	}
-
 	return nil
 }
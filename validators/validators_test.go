@@ -0,0 +1,262 @@
+package validators
+
+import (
+	serviceErrors "azyqs-auth-systems/errors"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateEmail(t *testing.T) {
+	tests := []struct {
+		name  string
+		email string
+		want  string
+	}{
+		{"valid", "alice@example.com", ""},
+		{"valid with plus and subdomain", "alice+tag@mail.example.co.id", ""},
+		{"missing at", "alice.example.com", "invalid_email_format"},
+		{"missing domain", "alice@", "invalid_email_format"},
+		{"short tld", "alice@example.c", "invalid_email_format"},
+		{"spaces", "alice @example.com", "invalid_email_format"},
+		{"empty", "", "invalid_email_format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertError(t, ValidateEmail(tt.email), tt.want)
+		})
+	}
+}
+
+func TestValidateUsername(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		want     string
+	}{
+		{"valid", "alice", ""},
+		{"valid with dots", "alice.b.c", ""},
+		{"valid min length", "abc", ""},
+		{"valid max length", strings.Repeat("a", 32), ""},
+		{"too short", "ab", "username_too_short"},
+		{"too long", strings.Repeat("a", 33), "username_too_long"},
+		{"leading dot", ".alice", "invalid_username_format"},
+		{"trailing dot", "alice.", "invalid_username_format"},
+		{"double dot", "ali..ce", "invalid_username_format"},
+		{"underscore", "ali_ce", "invalid_username_format"},
+		{"space", "ali ce", "invalid_username_format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertError(t, ValidateUsername(tt.username), tt.want)
+		})
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"valid", "Alice Liddell", ""},
+		{"valid min length", "Al", ""},
+		{"valid max length", strings.Repeat("a", 32), ""},
+		{"too short", "A", "name_too_short"},
+		{"too short after trim", "  A  ", "name_too_short"},
+		{"too long", strings.Repeat("a", 33), "name_too_long"},
+		{"empty", "", "name_too_short"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertError(t, ValidateName(tt.input), tt.want)
+		})
+	}
+}
+
+func TestValidatePassword(t *testing.T) {
+	personal := []string{"alice.l", "Alice Liddell", "wonder@example.com"}
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{"valid", "Passw0rd!", nil},
+		{"valid with symbol", "Passw0rd+", nil},
+		{"valid unicode", "Kata-Sandi-Ñ1", nil},
+		{"too short", "Pa0!", []string{"password_too_short", "password_too_weak"}},
+		{"empty", "", []string{"password_too_short", "password_missing_uppercase", "password_missing_lowercase",
+			"password_missing_digit", "password_missing_special", "password_too_weak"}},
+		{"too long", "Passw0rd!" + strings.Repeat("xy", 32), []string{"password_too_long"}},
+		{"missing upper", "passw0rd!", []string{"password_missing_uppercase"}},
+		{"missing lower", "PASSW0RD!", []string{"password_missing_lowercase"}},
+		{"missing digit", "Password!", []string{"password_missing_digit"}},
+		{"missing special", "Passw0rdd", []string{"password_missing_special"}},
+		{"missing several", "password", []string{"password_missing_uppercase", "password_missing_digit", "password_missing_special", "password_too_weak"}},
+		{"repeated", "Paaaassw0rd!", []string{"password_too_many_repeated_characters"}},
+		{"contains username", "Alice.L-2024!", []string{"password_contains_personal_info"}},
+		{"contains name word", "Liddell#2024x", []string{"password_contains_personal_info"}},
+		{"contains email local part", "xWonder#2024", []string{"password_contains_personal_info"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			if err := ValidatePassword(tt.password, personal...); err != nil {
+				for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+					got = append(got, e.Error())
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ValidatePassword(%q) = %v, want %v", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyCanBeRelaxed(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 4}
+	if err := policy.Check("abcd"); err != nil {
+		t.Fatalf("expected a relaxed policy to accept abcd, got %v", err)
+	}
+	if err := policy.Check("abc"); err == nil || err.Error() != "password_too_short" {
+		t.Fatalf("expected password_too_short, got %v", err)
+	}
+}
+
+func TestPasswordEntropy(t *testing.T) {
+	tests := []struct {
+		password string
+		want     float64
+	}{
+		{"", 0},
+		{"aaaa", 4 * math.Log2(26)},
+		{"aA1!", 4 * math.Log2(95)},
+	}
+	for _, tt := range tests {
+		if got := PasswordEntropy(tt.password); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("PasswordEntropy(%q) = %f, want %f", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"https", "https://hooks.example.com/azyqs", ""},
+		{"http with port", "http://localhost:9000/hook", ""},
+		{"relative", "/hook", "invalid_webhook_url"},
+		{"ftp scheme", "ftp://example.com/hook", "invalid_webhook_url"},
+		{"garbage", "://", "invalid_webhook_url"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertError(t, ValidateWebhookURL(tt.url), tt.want)
+		})
+	}
+}
+
+func TestValidateWebhookEvents(t *testing.T) {
+	known := []string{"user.registered", "user.deleted"}
+	tests := []struct {
+		name   string
+		events []string
+		want   string
+	}{
+		{"known", []string{"user.registered"}, ""},
+		{"wildcard", []string{"*"}, ""},
+		{"empty", nil, "webhook_events_required"},
+		{"unknown", []string{"user.registered", "user.exploded"}, "unknown_webhook_event"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertError(t, ValidateWebhookEvents(tt.events, known), tt.want)
+		})
+	}
+}
+
+func assertError(t *testing.T, err error, want string) {
+	t.Helper()
+	if want == "" {
+		if err != nil {
+			t.Fatalf("expected no error, got %q", err)
+		}
+		return
+	}
+	if err == nil || err.Error() != want {
+		t.Fatalf("expected error %q, got %v", want, err)
+	}
+}
+
+func TestResult(t *testing.T) {
+	var valid Result
+	valid.Check("email", ValidateEmail("alice@example.com"))
+	if err := valid.Err(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var result Result
+	result.Check("username", ValidateUsername("a"))
+	result.Check("email", ValidateEmail("nope"))
+	result.Check("password", RequirePassword(""))
+	result.Check("password", errors.New("password_mismatch"))
+	result.Check("name", ValidateName("Alice"))
+
+	var apiErr *serviceErrors.APIError
+	if !errors.As(result.Err(), &apiErr) || apiErr.Code != "validation_failed" {
+		t.Fatalf("expected validation_failed, got %v", result.Err())
+	}
+	want := map[string][]string{
+		"username": {"username_too_short"},
+		"email":    {"invalid_email_format"},
+		"password": {"password_required", "password_mismatch"},
+	}
+	if !reflect.DeepEqual(apiErr.Details, want) {
+		t.Fatalf("expected %v, got %v", want, apiErr.Details)
+	}
+}
+
+type breachedList []string
+
+func (b breachedList) Contains(password string) bool {
+	for _, leaked := range b {
+		if leaked == password {
+			return true
+		}
+	}
+	return false
+}
+
+func TestValidatePasswordRejectsBreached(t *testing.T) {
+	Breached = breachedList{"Passw0rd!", "weak"}
+	defer func() { Breached = nil }()
+
+	var result Result
+	result.Check("password", ValidatePassword("Passw0rd!"))
+	result.Check("other", ValidatePassword("weak"))
+	result.Check("fresh", ValidatePassword("Fr3sh-Passw0rd"))
+
+	var apiErr *serviceErrors.APIError
+	if !errors.As(result.Err(), &apiErr) {
+		t.Fatalf("expected a validation error, got %v", result.Err())
+	}
+	if got := apiErr.Details["password"]; !reflect.DeepEqual(got, []string{"password_compromised"}) {
+		t.Fatalf("expected only password_compromised, got %v", got)
+	}
+	if got := apiErr.Details["other"]; got[len(got)-1] != "password_compromised" {
+		t.Fatalf("expected policy violations followed by password_compromised, got %v", got)
+	}
+	if _, ok := apiErr.Details["fresh"]; ok {
+		t.Fatalf("expected a fresh password to pass, got %v", apiErr.Details["fresh"])
+	}
+}